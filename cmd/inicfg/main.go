@@ -0,0 +1,303 @@
+// inicfg 检查和查看配置文件
+//
+// 用法:
+//
+//	inicfg lint [-schema schema.json] base.ini prod.ini
+//	inicfg get [-origin] [-reveal] db.dsn base.ini prod.ini
+//	inicfg dump [-json] base.ini prod.ini
+//	inicfg diff staging.ini prod.ini
+//
+// 多个文件按顺序作为多层配置加载 后面的覆盖前面的
+// 没有设置主密钥时 get dump和diff不解密ENC(...)的值 按加密的值处理 get -reveal和lint需要主密钥
+// 退出码 0表示正常 1表示lint发现问题 get的key不存在或者diff有差异 2表示参数错误或者加载失败
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/go-irain/tools/config"
+	_ "github.com/go-irain/tools/config/toml"
+	_ "github.com/go-irain/tools/config/yaml"
+)
+
+const (
+	exitOK      = 0
+	exitProblem = 1
+	exitUsage   = 2
+)
+
+// loadOptions 所有子命令共用的加载设置
+type loadOptions struct {
+	extended bool
+	prefix   string
+	expand   bool
+}
+
+func (o *loadOptions) register(fs *flag.FlagSet) {
+	fs.BoolVar(&o.extended, "x", false, "使用扩展语法解析ini文件")
+	fs.StringVar(&o.prefix, "env", "", "环境变量覆盖层的前缀 如APP")
+	fs.BoolVar(&o.expand, "expand", false, "展开值中的${VAR}")
+}
+
+// load 加载files needkey为false时 没有主密钥也可以加载 ENC(...)的值保持不变
+func (o *loadOptions) load(strict, needkey bool, files ...string) (*config.Config, error) {
+	c := config.New()
+	c.SetStrict(strict)
+	if !needkey {
+		if key, err := config.ReadSecretKey(); err == nil && key == nil {
+			c.SetKeepEncrypted(true)
+		}
+	}
+	c.SetExtendedSyntax(o.extended)
+	if o.prefix != "" || o.expand {
+		c.SetEnv(config.EnvOptions{Prefix: o.prefix, Expand: o.expand})
+	}
+	return c, c.LoadFiles(files...)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(exitUsage)
+	}
+	var code int
+	switch os.Args[1] {
+	case "lint":
+		code = lint(os.Args[2:])
+	case "get":
+		code = get(os.Args[2:])
+	case "dump":
+		code = dump(os.Args[2:])
+	case "diff":
+		code = diff(os.Args[2:])
+	case "-h", "-help", "--help", "help":
+		usage()
+	default:
+		usage()
+		code = exitUsage
+	}
+	os.Exit(code)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: inicfg lint [-schema schema.json] file...")
+	fmt.Fprintln(os.Stderr, "       inicfg get [-origin] [-reveal] section.key file...")
+	fmt.Fprintln(os.Stderr, "       inicfg dump [-json] file...")
+	fmt.Fprintln(os.Stderr, "       inicfg diff a.ini b.ini")
+	fmt.Fprintln(os.Stderr, "common flags: -x (extended syntax) -env PREFIX -expand")
+}
+
+func newFlagSet(name string, opts *loadOptions) *flag.FlagSet {
+	fs := flag.NewFlagSet("inicfg "+name, flag.ExitOnError)
+	opts.register(fs)
+	return fs
+}
+
+// lint 严格模式加载 再按schema检查 有错误时返回1 -werror时警告也返回1
+func lint(args []string) int {
+	var opts loadOptions
+	fs := newFlagSet("lint", &opts)
+	schemafile := fs.String("schema", "", "json格式的schema文件")
+	werror := fs.Bool("werror", false, "警告也作为错误")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	var schema *config.Schema
+	if *schemafile != "" {
+		data, err := ioutil.ReadFile(*schemafile)
+		if err == nil {
+			schema = &config.Schema{}
+			err = json.Unmarshal(data, schema)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "inicfg: schema %s: %v\n", *schemafile, err)
+			return exitUsage
+		}
+	}
+
+	c, err := opts.load(true, true, fs.Args()...)
+	if err != nil {
+		if perr, ok := err.(config.ParseErrors); ok {
+			for _, d := range perr {
+				fmt.Println("error:", d)
+			}
+		} else {
+			fmt.Println("error:", err)
+		}
+		return exitProblem
+	}
+	code := exitOK
+	if schema != nil {
+		warnings, err := c.Validate(schema)
+		for _, w := range warnings {
+			fmt.Println("warning:", w)
+			if *werror {
+				code = exitProblem
+			}
+		}
+		if verr, ok := err.(config.ValidationErrors); ok {
+			for _, v := range verr {
+				fmt.Println("error:", v)
+			}
+			code = exitProblem
+		} else if err != nil {
+			fmt.Fprintln(os.Stderr, "inicfg:", err)
+			return exitUsage
+		}
+	}
+	if code == exitOK {
+		fmt.Println("ok")
+	}
+	return code
+}
+
+// splitName 把section.key分开 块名中可以包含.
+func splitName(name string) (string, string, bool) {
+	dot := strings.LastIndexByte(name, '.')
+	if dot < 0 {
+		return "", "", false
+	}
+	return name[:dot], name[dot+1:], true
+}
+
+func get(args []string) int {
+	var opts loadOptions
+	fs := newFlagSet("get", &opts)
+	origin := fs.Bool("origin", false, "输出每个值的来源")
+	reveal := fs.Bool("reveal", false, "输出加密值的明文")
+	fs.Parse(args)
+	if fs.NArg() < 2 {
+		fs.Usage()
+		return exitUsage
+	}
+	sec, key, ok := splitName(fs.Arg(0))
+	if !ok {
+		fmt.Fprintf(os.Stderr, "inicfg: %q is not section.key\n", fs.Arg(0))
+		return exitUsage
+	}
+	c, err := opts.load(false, *reveal, fs.Args()[1:]...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "inicfg:", err)
+		return exitUsage
+	}
+	v := c.View()
+	vals := v.GetValueSlice(sec, key)
+	if len(vals) == 0 {
+		fmt.Fprintf(os.Stderr, "inicfg: [%s] %s not found\n", sec, key)
+		return exitProblem
+	}
+	origins := v.Origins(sec, key)
+	for i, val := range vals {
+		if v.IsSecret(sec, key) && !*reveal {
+			val = config.Mask
+		}
+		if *origin && i < len(origins) {
+			fmt.Printf("%s\t# %s\n", val, origins[i])
+		} else {
+			fmt.Println(val)
+		}
+	}
+	return exitOK
+}
+
+func dump(args []string) int {
+	var opts loadOptions
+	fs := newFlagSet("dump", &opts)
+	asJSON := fs.Bool("json", false, "输出json 单个值为字符串 多个值为数组")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	c, err := opts.load(false, false, fs.Args()...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "inicfg:", err)
+		return exitUsage
+	}
+	if !*asJSON {
+		if err := c.Dump(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, "inicfg:", err)
+			return exitUsage
+		}
+		return exitOK
+	}
+	v := c.View()
+	out := make(map[string]map[string]interface{})
+	for _, sec := range v.Sections() {
+		values := make(map[string]interface{})
+		s := v.Section(sec)
+		for _, key := range s.Keys() {
+			vals := s.Values(key)
+			if v.IsSecret(sec, key) {
+				for i := range vals {
+					vals[i] = config.Mask
+				}
+			}
+			if len(vals) == 1 {
+				values[key] = vals[0]
+			} else {
+				values[key] = vals
+			}
+		}
+		out[sec] = values
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		fmt.Fprintln(os.Stderr, "inicfg:", err)
+		return exitUsage
+	}
+	return exitOK
+}
+
+// diff 比较两份配置生效后的值 和diff命令一样 有差异时返回1
+func diff(args []string) int {
+	var opts loadOptions
+	fs := newFlagSet("diff", &opts)
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return exitUsage
+	}
+	a, err := opts.load(false, false, fs.Arg(0))
+	if err == nil {
+		var b *config.Config
+		if b, err = opts.load(false, false, fs.Arg(1)); err == nil {
+			return printDiff(a, b)
+		}
+	}
+	fmt.Fprintln(os.Stderr, "inicfg:", err)
+	return exitUsage
+}
+
+func printDiff(a, b *config.Config) int {
+	changes := config.Diff(a, b)
+	show := func(c *config.Config, sec, key string, vals []string) string {
+		if c.IsSecret(sec, key) {
+			return config.Mask
+		}
+		return strings.Join(vals, ", ")
+	}
+	for _, ch := range changes {
+		switch ch.Type {
+		case config.KeyRemoved:
+			fmt.Printf("- [%s] %s = %s\n", ch.Section, ch.Key, show(a, ch.Section, ch.Key, ch.Old))
+		case config.KeyAdded:
+			fmt.Printf("+ [%s] %s = %s\n", ch.Section, ch.Key, show(b, ch.Section, ch.Key, ch.New))
+		case config.KeyChanged:
+			fmt.Printf("~ [%s] %s: %s -> %s\n", ch.Section, ch.Key,
+				show(a, ch.Section, ch.Key, ch.Old), show(b, ch.Section, ch.Key, ch.New))
+		}
+	}
+	if len(changes) > 0 {
+		return exitProblem
+	}
+	return exitOK
+}
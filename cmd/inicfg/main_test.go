@@ -0,0 +1,79 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-irain/tools/config"
+)
+
+// run 执行子命令 返回标准输出和退出码
+func run(t *testing.T, cmd func([]string) int, args ...string) (string, int) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	code := cmd(args)
+	os.Stdout = stdout
+	w.Close()
+	out, _ := ioutil.ReadAll(r)
+	return string(out), code
+}
+
+func writeConfig(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	name = filepath.Join(dir, name)
+	if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestReadOnlyWithoutKey(t *testing.T) {
+	for _, name := range []string{config.SecretKeyEnv, config.SecretKeyFileEnv} {
+		if old, ok := os.LookupEnv(name); ok {
+			os.Unsetenv(name)
+			defer os.Setenv(name, old)
+		}
+	}
+	dir, err := ioutil.TempDir("", "inicfg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	key := []byte("master")
+	enc1, _ := config.EncryptValue("p1", key)
+	enc2, _ := config.EncryptValue("p2", key)
+	a := writeConfig(t, dir, "a.ini", "[db]\nuser = root\npassword = "+enc1+"\n")
+	b := writeConfig(t, dir, "b.ini", "[db]\nuser = root\npassword = "+enc2+"\n")
+
+	out, code := run(t, get, "db.password", a)
+	if code != exitOK || out != config.Mask+"\n" {
+		t.Errorf("get: %d %q", code, out)
+	}
+	out, code = run(t, dump, a)
+	if code != exitOK || !strings.Contains(out, "password = "+config.Mask) || strings.Contains(out, "ENC(") {
+		t.Errorf("dump: %d %q", code, out)
+	}
+	out, code = run(t, diff, a, b)
+	if code != exitProblem || out != "~ [db] password: "+config.Mask+" -> "+config.Mask+"\n" {
+		t.Errorf("diff: %d %q", code, out)
+	}
+	// 需要明文时仍然需要主密钥
+	if _, code = run(t, get, "-reveal", "db.password", a); code != exitUsage {
+		t.Errorf("get -reveal without key: %d", code)
+	}
+
+	os.Setenv(config.SecretKeyEnv, string(key))
+	defer os.Unsetenv(config.SecretKeyEnv)
+	out, code = run(t, get, "-reveal", "db.password", a)
+	if code != exitOK || out != "p1\n" {
+		t.Errorf("get -reveal: %d %q", code, out)
+	}
+}
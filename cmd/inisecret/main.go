@@ -0,0 +1,162 @@
+// inisecret 加密和解密配置文件中ENC(...)形式的值
+//
+// 用法:
+//
+//	inisecret -key secret encrypt 'user:pass@tcp(db)/app'
+//	inisecret -keyfile master.key decrypt 'ENC(...)'
+//	inisecret rewrite -encrypt db.dsn,redis.password app.ini
+//	inisecret rewrite -decrypt app.ini
+//	inisecret rewrite -newkey newsecret app.ini
+//
+// 没有-key和-keyfile时读取环境变量CONFIG_SECRET_KEY或CONFIG_SECRET_KEY_FILE
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/go-irain/tools/config"
+)
+
+func main() {
+	key := flag.String("key", "", "主密钥 默认读取环境变量"+config.SecretKeyEnv)
+	keyfile := flag.String("keyfile", "", "从文件读取主密钥 默认读取环境变量"+config.SecretKeyFileEnv)
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	secret, erro := masterKey(*key, *keyfile)
+	if erro != nil {
+		fail(erro)
+	}
+	args := flag.Args()
+	switch args[0] {
+	case "encrypt", "decrypt":
+		value := ""
+		if len(args) > 1 {
+			value = args[1]
+		} else {
+			// 从标准输入读取 避免明文出现在命令历史中
+			line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+			value = strings.TrimRight(line, "\r\n")
+		}
+		var out string
+		if args[0] == "encrypt" {
+			out, erro = config.EncryptValue(value, secret)
+		} else {
+			out, erro = config.DecryptValue(value, secret)
+		}
+		if erro != nil {
+			fail(erro)
+		}
+		fmt.Println(out)
+	case "rewrite":
+		rewrite(args[1:], secret)
+	default:
+		usage()
+		os.Exit(2)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: inisecret [-key key | -keyfile file] encrypt|decrypt [value]")
+	fmt.Fprintln(os.Stderr, "       inisecret [-key key | -keyfile file] rewrite [-encrypt sec.key,...] [-decrypt] [-newkey key] file")
+	flag.PrintDefaults()
+}
+
+func fail(erro error) {
+	fmt.Fprintln(os.Stderr, "inisecret:", erro)
+	os.Exit(1)
+}
+
+func masterKey(key, keyfile string) ([]byte, error) {
+	if key != "" {
+		return []byte(key), nil
+	}
+	if keyfile != "" {
+		return config.ReadSecretKeyFile(keyfile)
+	}
+	secret, erro := config.ReadSecretKey()
+	if erro == nil && secret == nil {
+		erro = fmt.Errorf("key is empty, use -key, -keyfile, %s or %s", config.SecretKeyEnv, config.SecretKeyFileEnv)
+	}
+	return secret, erro
+}
+
+// rewrite 修改文件中的值 注释和顺序保持不变
+func rewrite(args []string, secret []byte) {
+	fs := flag.NewFlagSet("rewrite", flag.ExitOnError)
+	encrypt := fs.String("encrypt", "", "需要加密的key 逗号分隔 写作块名.key")
+	decrypt := fs.Bool("decrypt", false, "把全部加密的值改为明文")
+	newkey := fs.String("newkey", "", "使用新的主密钥重新加密全部加密的值")
+	extended := fs.Bool("x", false, "按扩展语法读写文件")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		usage()
+		os.Exit(2)
+	}
+	name := fs.Arg(0)
+	doc, erro := config.LoadDocument(name, *extended)
+	if erro != nil {
+		fail(erro)
+	}
+
+	targets := make(map[string]bool)
+	for _, item := range strings.Split(*encrypt, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			targets[item] = true
+		}
+	}
+	tokey := secret
+	if *newkey != "" {
+		tokey = []byte(*newkey)
+	}
+
+	changed := 0
+	for _, sec := range doc.Sections() {
+		for _, k := range doc.Keys(sec) {
+			vals := doc.Get(sec, k)
+			update := false
+			for i, val := range vals {
+				out := val
+				switch {
+				case config.IsEncrypted(val) && (*decrypt || *newkey != ""):
+					if out, erro = config.DecryptValue(val, secret); erro != nil {
+						fail(fmt.Errorf("[%s] %s: %v", sec, k, erro))
+					}
+					if !*decrypt {
+						out, erro = config.EncryptValue(out, tokey)
+					}
+				case !config.IsEncrypted(val) && targets[sec+"."+k]:
+					out, erro = config.EncryptValue(val, tokey)
+				}
+				if erro != nil {
+					fail(fmt.Errorf("[%s] %s: %v", sec, k, erro))
+				}
+				if out != val {
+					vals[i], update = out, true
+					changed++
+				}
+			}
+			if update {
+				if erro = doc.Set(sec, k, vals...); erro != nil {
+					fail(erro)
+				}
+			}
+		}
+	}
+	if changed == 0 {
+		fmt.Fprintln(os.Stderr, "inisecret: nothing to change")
+		return
+	}
+	if erro = doc.SaveFile(name); erro != nil {
+		fail(erro)
+	}
+	fmt.Fprintf(os.Stderr, "inisecret: %d value(s) rewritten in %s\n", changed, name)
+}
@@ -0,0 +1,72 @@
+// logdecrypt 把加密写入的日志文件解密后输出到控制台
+//
+// 用法:
+//
+//	logdecrypt -key secret app_last.log app_00000001.log
+//	LOG_CRYPT_KEY=secret logdecrypt < app_last.log
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/go-irain/tools/log"
+)
+
+func main() {
+	key := flag.String("key", os.Getenv("LOG_CRYPT_KEY"), "解密密钥 默认读取环境变量LOG_CRYPT_KEY")
+	keyfile := flag.String("keyfile", "", "从文件读取解密密钥")
+	flag.Parse()
+
+	secret := []byte(*key)
+	if *keyfile != "" {
+		data, erro := ioutil.ReadFile(*keyfile)
+		if erro != nil {
+			fmt.Fprintln(os.Stderr, "logdecrypt:", erro)
+			os.Exit(2)
+		}
+		secret = bytes.TrimRight(data, "\r\n")
+	}
+	if len(secret) == 0 {
+		fmt.Fprintln(os.Stderr, "logdecrypt: key is empty, use -key, -keyfile or LOG_CRYPT_KEY")
+		os.Exit(2)
+	}
+
+	if flag.NArg() == 0 {
+		os.Exit(decrypt(os.Stdout, os.Stderr, "stdin", os.Stdin, secret))
+	}
+	code := 0
+	for _, name := range flag.Args() {
+		f, erro := os.Open(name)
+		if erro != nil {
+			fmt.Fprintln(os.Stderr, "logdecrypt:", erro)
+			code = 1
+			continue
+		}
+		if c := decrypt(os.Stdout, os.Stderr, name, f, secret); c > code {
+			code = c
+		}
+		f.Close()
+	}
+	os.Exit(code)
+}
+
+// decrypt 把一个文件的明文输出到out 错误输出到errout
+// 末尾帧不完整只给出提示 其他错误返回非0
+func decrypt(out, errout io.Writer, name string, r io.Reader, key []byte) int {
+	_, erro := io.Copy(out, log.NewCryptReader(r, key))
+	switch erro {
+	case nil:
+		return 0
+	case log.ErrCryptTruncated:
+		fmt.Fprintf(errout, "logdecrypt: %s: %v\n", name, erro)
+		return 0
+	default:
+		fmt.Fprintf(errout, "logdecrypt: %s: %v\n", name, erro)
+		return 1
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-irain/tools/log"
+)
+
+func encryptedLog(t *testing.T, key []byte, lines ...string) []byte {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "logdecrypt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	w := log.NewWriteIO(dir+string(filepath.Separator), "app_", 64*log.MB, 3)
+	if err := w.SetEncryptKey(key); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range lines {
+		w.Write([]byte(line))
+	}
+	w.Close()
+	data, err := ioutil.ReadFile(filepath.Join(dir, "app_last.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDecrypt(t *testing.T) {
+	key := []byte("k")
+	data := encryptedLog(t, key, "a\n", "b\n")
+	tests := []struct {
+		name    string
+		data    []byte
+		key     string
+		code    int
+		out     string
+		errtext string
+	}{
+		{"ok", data, "k", 0, "a\nb\n", ""},
+		{"truncated", data[:len(data)-5], "k", 0, "a\n", "truncated"},
+		{"wrong key", data, "x", 1, "", "authentication failed"},
+		{"plain", []byte("a\n"), "k", 1, "", "invalid file header"},
+	}
+	for _, tt := range tests {
+		var out, errout bytes.Buffer
+		code := decrypt(&out, &errout, "f.log", bytes.NewReader(tt.data), []byte(tt.key))
+		if code != tt.code || out.String() != tt.out || !strings.Contains(errout.String(), tt.errtext) {
+			t.Errorf("%s: code %d out %q err %q", tt.name, code, out.String(), errout.String())
+		}
+	}
+}
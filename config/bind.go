@@ -0,0 +1,476 @@
+package config
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 结构体绑定
+//
+//	type DB struct {
+//		DSN     string            `ini:"dsn,required"`
+//		MaxConn int               `ini:"maxconn,default=10"`
+//		Hosts   []string          `ini:"host"`
+//		Timeout time.Duration     `ini:"timeout,default=3s"`
+//		Buffer  int64             `ini:"buffer,size,default=4MB"`
+//		Slave   Slave             `ini:"slave"`  // [db.slave]
+//		Params  map[string]string `ini:"params"` // [db.params]
+//		Weights map[string]int    `ini:"weight"` // weight[a] = 3
+//	}
+//
+// 没有ini标签时使用小写的字段名 标签为"-"时跳过
+// 数组字段使用重复的key 默认值中的数组用逗号分隔
+// 嵌套的结构体对应"块名.字段名"的子块
+// map字段对应"块名.字段名"的子块 以及块中name[x]和name.x形式的key 值可以是数组
+
+// FieldError 一个字段的绑定错误
+type FieldError struct {
+	// 值所在的位置 key不存在时为块所在的位置
+	Origin  Origin
+	Section string
+	Key     string
+	// 结构体字段的路径 如DB.Slave.Host
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	msg := fmt.Sprintf("[%s] %s (%s): %v", e.Section, e.Key, e.Field, e.Err)
+	if e.Origin.Line > 0 {
+		msg = e.Origin.String() + ": " + msg
+	}
+	return msg
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// BindErrors 绑定时收集到的所有错误
+type BindErrors []*FieldError
+
+func (e BindErrors) Error() string {
+	lines := make([]string, len(e))
+	for i, fe := range e {
+		lines[i] = fe.Error()
+	}
+	return fmt.Sprintf("config: %d field error(s):\n\t%s", len(e), strings.Join(lines, "\n\t"))
+}
+
+// ErrRequired 必填的key不存在
+var ErrRequired = errors.New("required key is missing")
+
+var (
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	durationType        = reflect.TypeOf(time.Duration(0))
+	timeType            = reflect.TypeOf(time.Time{})
+	urlType             = reflect.TypeOf(url.URL{})
+)
+
+// fieldTag 解析后的ini标签
+type fieldTag struct {
+	name       string
+	required   bool
+	size       bool
+	def        string
+	hasDefault bool
+}
+
+func parseFieldTag(f reflect.StructField) (fieldTag, bool) {
+	tag := f.Tag.Get("ini")
+	if tag == "-" {
+		return fieldTag{}, false
+	}
+	ft := fieldTag{}
+	parts := strings.Split(tag, ",")
+	ft.name = strings.TrimSpace(parts[0])
+	for i := 1; i < len(parts); i++ {
+		opt := strings.TrimSpace(parts[i])
+		switch {
+		case opt == "required":
+			ft.required = true
+		case opt == "size":
+			ft.size = true
+		case strings.HasPrefix(opt, "default="):
+			// 默认值可能包含逗号 取剩下的全部内容
+			rest := strings.TrimSpace(strings.Join(parts[i:], ","))
+			ft.def = strings.TrimPrefix(rest, "default=")
+			ft.hasDefault = true
+			i = len(parts)
+		}
+	}
+	if ft.name == "" {
+		ft.name = strings.ToLower(f.Name)
+	}
+	return ft, true
+}
+
+// binder 绑定时使用同一个View 并发的Reload不会让一个结构体混合两次加载的内容
+type binder struct {
+	v    View
+	errs BindErrors
+}
+
+func (b *binder) fail(sec, key, field string, index int, err error) {
+	o, _ := b.v.Origin(sec, key)
+	if list := b.v.Origins(sec, key); index > 0 && index < len(list) {
+		o = list[index]
+	}
+	b.errs = append(b.errs, &FieldError{Origin: o, Section: sec, Key: key, Field: field, Err: err})
+}
+
+// isScalar 判断类型是否作为单个值处理 而不是子块
+func isScalar(t reflect.Type) bool {
+	if reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return true
+	}
+	switch t {
+	case durationType, timeType, urlType:
+		return true
+	}
+	return t.Kind() != reflect.Struct && t.Kind() != reflect.Map && t.Kind() != reflect.Slice
+}
+
+// bindStruct 把[sec]块填充到结构体v
+func (b *binder) bindStruct(sec string, v reflect.Value, path string) {
+	values := b.v.GetSection(sec)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		ft, ok := parseFieldTag(f)
+		if !ok {
+			continue
+		}
+		fv := v.Field(i)
+		field := path + "." + f.Name
+		ftype := f.Type
+		if ftype.Kind() == reflect.Ptr && !isScalar(ftype.Elem()) && ftype.Elem().Kind() == reflect.Struct {
+			// 结构体指针 子块存在时才分配
+			if b.v.GetSection(sec+"."+ft.name) == nil && !ft.required {
+				continue
+			}
+			if fv.IsNil() {
+				fv.Set(reflect.New(ftype.Elem()))
+			}
+			fv, ftype = fv.Elem(), ftype.Elem()
+		}
+		switch {
+		case ftype.Kind() == reflect.Struct && !isScalar(ftype):
+			sub := sec + "." + ft.name
+			if ft.required && b.v.GetSection(sub) == nil {
+				b.fail(sec, ft.name, field, 0, fmt.Errorf("required section [%s] is missing", sub))
+				continue
+			}
+			b.bindStruct(sub, fv, field)
+		case ftype.Kind() == reflect.Map:
+			b.bindMap(sec, ft, fv, field)
+		case ftype.Kind() == reflect.Slice && ftype.Elem().Kind() != reflect.Uint8:
+			b.bindSlice(sec, values[ft.name], ft, fv, field)
+		default:
+			raws := values[ft.name]
+			if len(raws) == 0 {
+				if ft.required {
+					b.fail(sec, ft.name, field, 0, ErrRequired)
+					continue
+				}
+				if !ft.hasDefault {
+					continue
+				}
+				if err := setValue(fv, ft.def, ft.size); err != nil {
+					b.fail(sec, ft.name, field, 0, fmt.Errorf("invalid default %q: %v", ft.def, err))
+				}
+				continue
+			}
+			if err := setValue(fv, raws[0], ft.size); err != nil {
+				b.fail(sec, ft.name, field, 0, fmt.Errorf("invalid value %q: %v", raws[0], err))
+			}
+		}
+	}
+}
+
+// bindSlice 使用重复的key填充数组
+func (b *binder) bindSlice(sec string, raws []string, ft fieldTag, fv reflect.Value, field string) {
+	if len(raws) == 0 {
+		if ft.required {
+			b.fail(sec, ft.name, field, 0, ErrRequired)
+			return
+		}
+		if !ft.hasDefault {
+			return
+		}
+		raws = strings.Split(ft.def, ",")
+		for i := range raws {
+			raws[i] = strings.TrimSpace(raws[i])
+		}
+	}
+	slice := reflect.MakeSlice(fv.Type(), len(raws), len(raws))
+	for i, raw := range raws {
+		if err := setValue(slice.Index(i), raw, ft.size); err != nil {
+			b.fail(sec, ft.name, field, i, fmt.Errorf("invalid value %q: %v", raw, err))
+		}
+	}
+	fv.Set(slice)
+}
+
+// mapEntry map的一个元素在配置中的位置和值
+type mapEntry struct {
+	sec, key string
+	vals     []string
+}
+
+// mapSupported map的key必须是字符串 值是单个值或者单个值的数组
+func mapSupported(t reflect.Type) bool {
+	if t.Key().Kind() != reflect.String {
+		return false
+	}
+	elem := t.Elem()
+	if elem.Kind() == reflect.Slice && elem.Elem().Kind() != reflect.Uint8 {
+		elem = elem.Elem()
+	}
+	return isScalar(elem) && elem.Kind() != reflect.Slice
+}
+
+// bindMap 使用[sec.name]子块和[sec]中name[x] name.x形式的key填充map
+// 两处都有的子key使用[sec]中的值
+func (b *binder) bindMap(sec string, ft fieldTag, fv reflect.Value, field string) {
+	sub := sec + "." + ft.name
+	if !mapSupported(fv.Type()) {
+		b.fail(sec, ft.name, field, 0, fmt.Errorf("unsupported map type %s, need map[string]T or map[string][]T", fv.Type()))
+		return
+	}
+	entries := make(map[string]mapEntry)
+	values := b.v.GetSection(sub)
+	for key, vals := range values {
+		entries[key] = mapEntry{sec: sub, key: key, vals: vals}
+	}
+	inline := b.v.Section(sec)
+	for _, key := range inline.Keys() {
+		name, ok := subKey(key, ft.name)
+		if !ok {
+			continue
+		}
+		e, has := entries[name]
+		if !has || e.sec != sec {
+			e = mapEntry{sec: sec, key: key}
+		}
+		e.vals = append(e.vals, inline.Values(key)...)
+		entries[name] = e
+	}
+	if values == nil && len(entries) == 0 {
+		if ft.required {
+			b.fail(sec, ft.name, field, 0, fmt.Errorf("required section [%s] or keys %s[...] are missing", sub, ft.name))
+		}
+		return
+	}
+	fv.Set(b.makeMap(fv.Type(), entries, ft.size, field))
+}
+
+// makeMap 转换每个元素的值 生成类型为t的map
+func (b *binder) makeMap(t reflect.Type, entries map[string]mapEntry, size bool, field string) reflect.Value {
+	m := reflect.MakeMap(t)
+	elem := t.Elem()
+	for name, e := range entries {
+		ev := reflect.New(elem).Elem()
+		efield := field + "[" + name + "]"
+		if elem.Kind() == reflect.Slice {
+			slice := reflect.MakeSlice(elem, len(e.vals), len(e.vals))
+			for i, raw := range e.vals {
+				if err := setValue(slice.Index(i), raw, size); err != nil {
+					b.fail(e.sec, e.key, efield, i, fmt.Errorf("invalid value %q: %v", raw, err))
+				}
+			}
+			ev.Set(slice)
+		} else if len(e.vals) > 0 {
+			if err := setValue(ev, e.vals[0], size); err != nil {
+				b.fail(e.sec, e.key, efield, 0, fmt.Errorf("invalid value %q: %v", e.vals[0], err))
+			}
+		}
+		m.SetMapIndex(reflect.ValueOf(name).Convert(t.Key()), ev)
+	}
+	return m
+}
+
+// setValue 把字符串转换为v的类型
+func setValue(v reflect.Value, raw string, size bool) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setValue(v.Elem(), raw, size)
+	}
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(raw))
+	}
+	switch v.Type() {
+	case durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	case timeType:
+		t, err := ParseTime(raw)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	case urlType:
+		u, err := parseURL(raw)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(*u))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		var err error
+		if size {
+			n, err = ParseSize(raw)
+		} else {
+			n, err = strconv.ParseInt(raw, 10, v.Type().Bits())
+		}
+		if err != nil {
+			return numError(err)
+		}
+		if v.OverflowInt(n) {
+			return strconv.ErrRange
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var n uint64
+		if size {
+			s, err := ParseSize(raw)
+			if err != nil {
+				return err
+			}
+			n = uint64(s)
+		} else {
+			var err error
+			if n, err = strconv.ParseUint(raw, 10, v.Type().Bits()); err != nil {
+				return numError(err)
+			}
+		}
+		if v.OverflowUint(n) {
+			return strconv.ErrRange
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, v.Type().Bits())
+		if err != nil {
+			return numError(err)
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
+	}
+	return nil
+}
+
+// structPtr 检查dst是指向结构体的非空指针
+func structPtr(dst interface{}) (reflect.Value, error) {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("config: need a non-nil pointer to struct, got %T", dst)
+	}
+	return v.Elem(), nil
+}
+
+// Unmarshal 把[section]块的内容填充到dst指向的结构体
+// 所有字段的错误一起返回 类型为BindErrors
+func (c *Config) Unmarshal(section string, dst interface{}) error {
+	v, err := structPtr(dst)
+	if err != nil {
+		return err
+	}
+	b := &binder{v: c.View()}
+	b.bindStruct(section, v, v.Type().Name())
+	if len(b.errs) > 0 {
+		return b.errs
+	}
+	return nil
+}
+
+// UnmarshalAll 把整个配置填充到root指向的结构体
+// root的每个结构体字段对应一个块 map字段对应块中的全部key
+func (c *Config) UnmarshalAll(root interface{}) error {
+	v, err := structPtr(root)
+	if err != nil {
+		return err
+	}
+	b := &binder{v: c.View()}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		ft, ok := parseFieldTag(f)
+		if !ok {
+			continue
+		}
+		fv := v.Field(i)
+		field := t.Name() + "." + f.Name
+		if fv.Kind() == reflect.Ptr && fv.Type().Elem().Kind() == reflect.Struct {
+			if b.v.GetSection(ft.name) == nil && !ft.required {
+				continue
+			}
+			if fv.IsNil() {
+				fv.Set(reflect.New(fv.Type().Elem()))
+			}
+			fv = fv.Elem()
+		}
+		if ft.required && b.v.GetSection(ft.name) == nil {
+			b.fail(ft.name, "", field, 0, fmt.Errorf("required section [%s] is missing", ft.name))
+			continue
+		}
+		switch {
+		case fv.Kind() == reflect.Struct && !isScalar(fv.Type()):
+			b.bindStruct(ft.name, fv, field)
+		case fv.Kind() == reflect.Map && mapSupported(fv.Type()):
+			if values := b.v.GetSection(ft.name); values != nil {
+				entries := make(map[string]mapEntry, len(values))
+				for key, vals := range values {
+					entries[key] = mapEntry{sec: ft.name, key: key, vals: vals}
+				}
+				fv.Set(b.makeMap(fv.Type(), entries, ft.size, field))
+			}
+		default:
+			b.fail(ft.name, "", field, 0, fmt.Errorf("unsupported root field type %s, need struct or map", fv.Type()))
+		}
+	}
+	if len(b.errs) > 0 {
+		return b.errs
+	}
+	return nil
+}
+
+// Unmarshal 把默认配置中[section]块的内容填充到dst
+func Unmarshal(section string, dst interface{}) error {
+	return defConfig.Unmarshal(section, dst)
+}
+
+// UnmarshalAll 把默认配置填充到root
+func UnmarshalAll(root interface{}) error {
+	return defConfig.UnmarshalAll(root)
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testSlave struct {
+	Host string `ini:"host,required"`
+	Port int    `ini:"port,default=3306"`
+}
+
+type testDB struct {
+	DSN     string            `ini:"dsn,required"`
+	MaxConn int               `ini:"maxconn,default=10"`
+	Hosts   []string          `ini:"host"`
+	Ports   []int             `ini:"port,default=1,2"`
+	Timeout time.Duration     `ini:"timeout,default=3s"`
+	Buffer  int64             `ini:"buffer,size,default=4MB"`
+	Debug   *bool             `ini:"debug"`
+	Slave   testSlave         `ini:"slave"`
+	Backup  *testSlave        `ini:"backup"`
+	Params  map[string]string `ini:"params"`
+	Ignored string            `ini:"-"`
+	Name    string
+}
+
+func TestUnmarshal(t *testing.T) {
+	c := mustLoad(t, `[db]
+dsn = user@tcp/app
+host = a
+host = b
+timeout = 1m
+debug = on
+name = main
+ignored = x
+[db.slave]
+host = s1
+[db.params]
+charset = utf8
+`)
+	var db testDB
+	if err := c.Unmarshal("db", &db); err != nil {
+		t.Fatal(err)
+	}
+	if db.DSN != "user@tcp/app" || db.MaxConn != 10 || len(db.Hosts) != 2 || db.Hosts[1] != "b" {
+		t.Errorf("scalars: %+v", db)
+	}
+	if len(db.Ports) != 2 || db.Ports[1] != 2 || db.Timeout != time.Minute || db.Buffer != 4<<20 {
+		t.Errorf("defaults: %+v", db)
+	}
+	if db.Debug == nil || !*db.Debug || db.Name != "main" || db.Ignored != "" {
+		t.Errorf("pointer, lower-case name or ignored field: %+v", db)
+	}
+	if db.Slave.Host != "s1" || db.Slave.Port != 3306 || db.Backup != nil {
+		t.Errorf("nested: %+v %+v", db.Slave, db.Backup)
+	}
+	if db.Params["charset"] != "utf8" {
+		t.Errorf("map: %v", db.Params)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	c := New()
+	err := c.LoadString(`[db]
+maxconn = many
+port = 1
+port = x
+[db.slave]
+port = 1
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var db testDB
+	err = c.Unmarshal("db", &db)
+	var berr BindErrors
+	if !errors.As(err, &berr) {
+		t.Fatalf("got %T %v", err, err)
+	}
+	want := []string{
+		"[db] dsn (testDB.DSN): required key is missing",
+		`line 2: [db] maxconn (testDB.MaxConn): invalid value "many"`,
+		`line 4: [db] port (testDB.Ports): invalid value "x"`,
+		"[db.slave] host (testDB.Slave.Host): required key is missing",
+	}
+	if len(berr) != len(want) {
+		t.Fatalf("got %d errors:\n%v", len(berr), err)
+	}
+	for i, w := range want {
+		if !strings.Contains(berr[i].Error(), w) {
+			t.Errorf("error %d: got %q, want %q", i, berr[i].Error(), w)
+		}
+	}
+	if !errors.Is(berr[0], ErrRequired) {
+		t.Error("missing key is not ErrRequired")
+	}
+}
+
+func TestUnmarshalAll(t *testing.T) {
+	c := mustLoad(t, `[db]
+dsn = x
+[db.slave]
+host = s
+[vars]
+a = 1
+`)
+	var root struct {
+		DB    testDB            `ini:"db,required"`
+		Cache *testSlave        `ini:"cache"`
+		Vars  map[string]string `ini:"vars"`
+	}
+	if err := c.UnmarshalAll(&root); err != nil {
+		t.Fatal(err)
+	}
+	if root.DB.DSN != "x" || root.Cache != nil || root.Vars["a"] != "1" {
+		t.Errorf("%+v", root)
+	}
+	if err := c.UnmarshalAll(root); err == nil {
+		t.Error("non-pointer accepted")
+	}
+}
@@ -1,89 +1,245 @@
 package config
 
 import (
-	"bufio"
+	"io"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 type ConfigSection map[string][]string
 type configFile map[string]ConfigSection
 
-var defConfig *configFile
+// Config 一份解析后的配置文件
+// 零值可以直接使用 没有加载时所有查询返回空
+type Config struct {
+	// 当前生效的内容 *snapshot 重新加载时整体替换
+	snap atomic.Value
+	// 环境变量的展开和覆盖 nil表示不处理
+	env *EnvOptions
+	// 多层配置合并时数组key的处理方式
+	mode MergeMode
+	// 严格模式 有问题的行让加载失败
+	strict bool
+	// 扩展语法 引号 转义 行尾注释 续行和heredoc
+	extended bool
+	// 解密ENC(...)的主密钥 nil时从环境变量读取
+	secretkey []byte
+	// 不解密ENC(...)的值 原样保留
+	keepencrypted bool
+	// 读取@file:引用的设置 nil时使用默认值
+	fileopts *FileOptions
+	// 加载时检查的声明
+	schema *Schema
 
-// 根据名字获取对应的配置文件
-func SetConfig(name string) error {
-	f, err := os.Open(name)
-	if err != nil {
-		return err
+	// 串行执行prepare和替换内容 保证raw和snap是对应的 后开始的更新不会被先开始的覆盖
+	update sync.Mutex
+	lock   sync.Mutex
+	// 最近一次从文件加载的方式 用于Reload 从io.Reader加载时为nil
+	loader func() (*snapshot, error)
+	// 最近一次解析的结果 没有应用环境变量等覆盖层
+	raw *snapshot
+	// 命令行参数的覆盖层 块名 -> key -> 值
+	flags map[string]map[string][]string
+	// 内容变化和重新加载失败的回调
+	onChange []func([]Change)
+	onError  []func(error)
+}
+
+// New 创建一个空的配置 使用Load系列方法加载内容
+func New() *Config {
+	return &Config{}
+}
+
+// Load 从r读取并解析ini格式的配置 成功后替换当前内容
+// 从io.Reader加载的内容不能Reload
+func (c *Config) Load(r io.Reader) error {
+	return c.loadWith(func() (*snapshot, error) {
+		return parse(r, "", c.parseOptions())
+	}, false)
+}
+
+// parseOptions 当前的解析设置
+func (c *Config) parseOptions() parseOptions {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return parseOptions{mode: c.mode, extended: c.extended}
+}
+
+// loadWith 使用loader加载并替换当前内容 reloadable为true时记录loader用于Reload
+func (c *Config) loadWith(loader func() (*snapshot, error), reloadable bool) error {
+	keep := loader
+	if !reloadable {
+		keep = nil
 	}
-	defer f.Close()
-	//解析ini文件
-	r := bufio.NewReader(f)
-	var (
-		line string
-		sec  string
-	)
-	sections := make(configFile)
-	for err == nil {
-		line, err = r.ReadString('\n')
-		line = strings.TrimSpace(line)
-		//空行或者注释跳过 注释支持;和#开头的行
-		if line == "" || line[0] == ';' || line[0] == '#' {
-			continue
-		}
-		//判断配置块[name]
-		if line[0] == '[' && line[len(line)-1] == ']' {
-			sec = line[1 : len(line)-1]
-			_, has := sections[sec]
-			if !has {
-				sections[sec] = make(ConfigSection)
-			}
-			continue
-		}
-		if sec == "" {
-			continue
-		}
-		pair := strings.SplitN(line, "=", 2)
-		if len(pair) != 2 {
-			continue
+	return c.publish(loader, func() { c.loader = keep })
+}
+
+// prepare 按严格模式检查后 复制解析的结果依次应用环境变量覆盖 命令行参数
+// 块继承 块引用 环境变量展开 读取引用的文件 解密 最后按声明检查 raw保持不变
+// 严格模式先检查 有问题的行导致的后续错误(如缺少必需的key)不会掩盖ParseErrors
+func (c *Config) prepare(raw *snapshot) (*snapshot, error) {
+	if err := c.check(raw); err != nil {
+		return nil, err
+	}
+	c.lock.Lock()
+	env := c.env
+	c.lock.Unlock()
+	snap := raw.clone()
+	if env != nil {
+		env.override(snap)
+	}
+	c.applyFlags(snap)
+	if err := snap.resolveInherits(); err != nil {
+		return nil, err
+	}
+	if c.parseOptions().extended {
+		if err := snap.resolveRefs(); err != nil {
+			return nil, err
 		}
-		key, val := strings.TrimSpace(pair[0]), strings.TrimSpace(pair[1])
-		if key == "" || val == "" {
-			continue
+	}
+	if env != nil {
+		env.expand(snap)
+	}
+	if err := c.readRefs(snap); err != nil {
+		return nil, err
+	}
+	if err := c.decrypt(snap); err != nil {
+		return nil, err
+	}
+	if err := c.validateOnLoad(snap); err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// refresh 使用最近一次解析的结果重新应用覆盖层 用于命令行参数变化之后
+// 还没有加载时什么也不做
+func (c *Config) refresh() error {
+	return c.publish(nil, nil)
+}
+
+// publish 调用load读取后应用覆盖层并替换当前内容 成功后通知订阅者
+// load返回没有应用覆盖层的解析结果 为nil时使用最近一次解析的结果 还没有加载时什么也不做
+// 读取和替换都在c.update中进行 并发加载时后读取的内容一定后生效 不会被较早的读取覆盖
+// done在持有c.lock时和替换一起执行
+func (c *Config) publish(load func() (*snapshot, error), done func()) error {
+	c.update.Lock()
+	var raw *snapshot
+	if load != nil {
+		var err error
+		if raw, err = load(); err != nil {
+			c.update.Unlock()
+			return err
 		}
-		if slice, has := sections[sec][key]; has {
-			slice = append(slice, val)
-			sections[sec][key] = slice
-		} else {
-			sections[sec][key] = []string{val}
+	} else {
+		c.lock.Lock()
+		raw = c.raw
+		c.lock.Unlock()
+		if raw == nil {
+			c.update.Unlock()
+			return nil
 		}
 	}
-	defConfig = &sections
+	snap, err := c.prepare(raw)
+	if err != nil {
+		c.update.Unlock()
+		return err
+	}
+	c.lock.Lock()
+	c.raw = raw
+	if done != nil {
+		done()
+	}
+	old := c.snapshot()
+	c.snap.Store(snap)
+	c.lock.Unlock()
+	if commit := raw.commit; commit != nil {
+		raw.commit = nil
+		commit()
+	}
+	c.update.Unlock()
+	// 回调中可以再次加载 不持有锁
+	if old != nil {
+		c.notify(old, snap)
+	}
 	return nil
 }
 
+// snapshot 当前生效的内容 没有加载时为nil
+func (c *Config) snapshot() *snapshot {
+	snap, _ := c.snap.Load().(*snapshot)
+	return snap
+}
+
+// LoadFile 根据文件名加载配置 按扩展名选择格式 见RegisterFormat
+func (c *Config) LoadFile(name string) error {
+	return c.loadWith(func() (*snapshot, error) {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return parseFormat(f, filepath.Ext(name), name, c.parseOptions())
+	}, true)
+}
+
+// LoadString 从字符串加载配置 方便测试时使用
+func (c *Config) LoadString(s string) error {
+	return c.Load(strings.NewReader(s))
+}
+
+// GetSection 返回块的副本 修改返回的内容不影响配置 块不存在时返回nil
+// 只读取少量key时使用GetValue或Section 避免复制整个块
+func (c *Config) GetSection(sec string) ConfigSection {
+	return c.View().GetSection(sec)
+}
+
+// Origin 返回[sec]中key第一个值所在的位置
+// key不存在时返回块所在的位置 块也不存在时第二个返回值为false
+func (c *Config) Origin(sec, key string) (Origin, bool) {
+	return c.View().Origin(sec, key)
+}
+
+// Origins 返回[sec]中key每个值的来源 和GetValueSlice一一对应
+func (c *Config) Origins(sec, key string) []Origin {
+	return c.View().Origins(sec, key)
+}
+
+// GetValueSlice 返回key全部值的副本
+func (c *Config) GetValueSlice(sec, key string) []string {
+	return c.View().GetValueSlice(sec, key)
+}
+
+func (c *Config) GetValue(sec, key string) string {
+	return c.View().GetValue(sec, key)
+}
+
+// 包级别的函数使用的默认配置
+var defConfig = New()
+
+// Default 返回包级别函数使用的默认配置
+func Default() *Config {
+	return defConfig
+}
+
+// 根据名字获取对应的配置文件
+func SetConfig(name string) error {
+	return defConfig.LoadFile(name)
+}
+
 func GetSection(sec string) ConfigSection {
-	if defConfig == nil {
-		return nil
-	}
-	return (*defConfig)[sec]
+	return defConfig.GetSection(sec)
 }
 
 func GetValueSlice(sec, key string) []string {
-	if seco := GetSection(sec); seco == nil {
-		return []string{}
-	} else {
-		return seco.GetValueSlice(key)
-	}
+	return defConfig.GetValueSlice(sec, key)
 }
 
 func GetValue(sec, key string) string {
-	s := GetValueSlice(sec, key)
-	if len(s) > 0 {
-		return s[0]
-	}
-	return ""
+	return defConfig.GetValue(sec, key)
 }
 
 func (m ConfigSection) GetValueSlice(key string) []string {
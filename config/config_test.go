@@ -0,0 +1,115 @@
+package config
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// TestConcurrentReload 使用go test -race运行 读取 重新加载 命令行参数和设置同时进行
+func TestConcurrentReload(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"app.ini": "[db]\nhost = h0\nport = 0\n[db.slave]\nhost = h0\n"})
+	name := filepath.Join(dir, "app.ini")
+	c := New()
+	if err := c.LoadFile(name); err != nil {
+		t.Fatal(err)
+	}
+	fs := flag.NewFlagSet("app", flag.ContinueOnError)
+	c.BindFlag(fs, "db", "user", "")
+	var changes int32
+	c.OnChange(func([]Change) { atomic.AddInt32(&changes, 1) })
+
+	const n = 50
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				v := c.View()
+				// 同一个View中的值来自同一次加载
+				if v.GetValue("db", "host") != "h"+v.GetValue("db", "port") {
+					t.Errorf("inconsistent view: %v", v.Section("db"))
+					return
+				}
+				// Unmarshal的全部字段也来自同一次加载 包括子块
+				var db struct {
+					Port  string `ini:"port"`
+					Slave struct {
+						Host string `ini:"host"`
+					} `ini:"slave"`
+				}
+				if err := c.Unmarshal("db", &db); err != nil || db.Slave.Host != "h"+db.Port {
+					t.Errorf("inconsistent unmarshal: %+v %v", db, err)
+					return
+				}
+				c.GetValue("db", "user")
+				c.Origin("db", "host")
+				c.IsSecret("db", "host")
+			}
+		}()
+	}
+	var writers sync.WaitGroup
+	writers.Add(2)
+	go func() {
+		defer writers.Done()
+		for i := 1; i <= n; i++ {
+			s := strconv.Itoa(i)
+			if err := ioutil.WriteFile(name, []byte("[db]\nhost = h"+s+"\nport = "+s+"\n[db.slave]\nhost = h"+s+"\n"), 0644); err != nil {
+				t.Error(err)
+				return
+			}
+			if err := c.Reload(); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	go func() {
+		defer writers.Done()
+		for i := 0; i < n; i++ {
+			if err := fs.Set("db.user", "u"+strconv.Itoa(i)); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	// 设置在加载期间一直修改
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			c.SetEnv(EnvOptions{Prefix: "TEST_CONCURRENT"})
+			c.SetMergeMode(MergeReplace)
+			c.SetExtendedSyntax(false)
+			c.SetStrict(false)
+		}
+	}()
+	writers.Wait()
+	close(done)
+	wg.Wait()
+
+	// 最后一次重新加载和全部参数都生效
+	users := c.GetValueSlice("db", "user")
+	if c.GetValue("db", "host") != "h"+strconv.Itoa(n) || len(users) != n || users[n-1] != "u"+strconv.Itoa(n-1) {
+		t.Errorf("final: %v", c.GetSection("db"))
+	}
+	if atomic.LoadInt32(&changes) == 0 {
+		t.Error("no change notified")
+	}
+}
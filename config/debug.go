@@ -0,0 +1,156 @@
+package config
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// 查看每个值从哪里来
+//
+//	src, _ := config.Source("db", "dsn")
+//	fmt.Println(src.Origins[0]) // env APP_DB_DSN
+//
+//	h, _ := config.DebugHandler(config.DebugOptions{})
+//	http.Handle("/debug/config", h)
+//
+// 调试接口输出生效的全部值和来源 ?format=json输出json ?section=db只输出一个块
+// 加密保存 从文件读取和名字匹配MaskPattern的值输出为Mask
+
+// ValueSource 一个key生效的值和每个值的来源
+type ValueSource struct {
+	Section string
+	Key     string
+	Values  []string
+	// 和Values一一对应
+	Origins []Origin
+	// 值是加密保存或者从文件读取的
+	Secret bool
+}
+
+// Source 返回[sec]中key的值和来源 key不存在时第二个返回值为false
+func (v View) Source(sec, key string) (ValueSource, bool) {
+	vals := v.Section(sec).valuesOrNil(key)
+	if vals == nil {
+		return ValueSource{}, false
+	}
+	return ValueSource{Section: sec, Key: key, Values: vals, Origins: v.Origins(sec, key), Secret: v.IsSecret(sec, key)}, true
+}
+
+// Source 返回[sec]中key的值和来源 key不存在时第二个返回值为false
+func (c *Config) Source(sec, key string) (ValueSource, bool) {
+	return c.View().Source(sec, key)
+}
+
+// Source 返回默认配置中[sec] key的值和来源
+func Source(sec, key string) (ValueSource, bool) {
+	return defConfig.Source(sec, key)
+}
+
+// DefaultMaskPattern 默认隐藏的key
+const DefaultMaskPattern = `(?i)pass|secret|token|credential|private|api_?key`
+
+// DebugOptions 调试接口的设置
+type DebugOptions struct {
+	// MaskPattern 和"块名.key"匹配的值输出时隐藏 为空时使用DefaultMaskPattern
+	MaskPattern string
+}
+
+type debugHandler struct {
+	c    *Config
+	mask *regexp.Regexp
+}
+
+// DebugHandler 返回输出当前生效内容和来源的http.Handler
+func (c *Config) DebugHandler(opts DebugOptions) (http.Handler, error) {
+	if opts.MaskPattern == "" {
+		opts.MaskPattern = DefaultMaskPattern
+	}
+	mask, err := regexp.Compile(opts.MaskPattern)
+	if err != nil {
+		return nil, err
+	}
+	return &debugHandler{c: c, mask: mask}, nil
+}
+
+// DebugHandler 返回默认配置的调试接口
+func DebugHandler(opts DebugOptions) (http.Handler, error) {
+	return defConfig.DebugHandler(opts)
+}
+
+// debugValue json输出中的一个值
+type debugValue struct {
+	Value  string `json:"value"`
+	Origin string `json:"origin"`
+	Layer  string `json:"layer"`
+}
+
+// sources 按块名和key的字母顺序返回全部值 需要隐藏的值替换为Mask
+func (h *debugHandler) sources(only string) []ValueSource {
+	v := h.c.View()
+	var list []ValueSource
+	for _, sec := range v.Sections() {
+		if only != "" && sec != only {
+			continue
+		}
+		for _, key := range v.Section(sec).Keys() {
+			src, _ := v.Source(sec, key)
+			if src.Secret || h.mask.MatchString(sec+"."+key) {
+				for i := range src.Values {
+					src.Values[i] = Mask
+				}
+			}
+			list = append(list, src)
+		}
+	}
+	return list
+}
+
+func (h *debugHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	list := h.sources(r.FormValue("section"))
+	if r.FormValue("format") == "json" {
+		out := make(map[string]map[string][]debugValue)
+		for _, src := range list {
+			if out[src.Section] == nil {
+				out[src.Section] = make(map[string][]debugValue)
+			}
+			vals := make([]debugValue, len(src.Values))
+			for i, val := range src.Values {
+				vals[i] = debugValue{Value: val}
+				if i < len(src.Origins) {
+					vals[i].Origin, vals[i].Layer = src.Origins[i].String(), src.Origins[i].Layer()
+				}
+			}
+			out[src.Section][src.Key] = vals
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(out)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	sec := "\x00"
+	for _, src := range list {
+		if src.Section != sec {
+			if sec != "\x00" {
+				bw.WriteString("\n")
+			}
+			sec = src.Section
+			if sec != "" {
+				bw.WriteString("[" + sec + "]\n")
+			}
+		}
+		for i, val := range src.Values {
+			line := src.Key + " = " + strings.Replace(val, "\n", `\n`, -1)
+			if i < len(src.Origins) {
+				line += "\t; " + src.Origins[i].String()
+			}
+			bw.WriteString(line + "\n")
+		}
+	}
+	bw.Flush()
+}
@@ -0,0 +1,110 @@
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.ini":   "include = mid.ini\n[db]\nport = 3307\n",
+		"mid.ini":    "include = common.ini\n",
+		"common.ini": "[db]\nhost = common\nhost = backup\n",
+	})
+	c := New()
+	c.SetEnv(fakeEnv(EnvOptions{Prefix: "APP"}, map[string]string{"APP_DB_USER": "root"}))
+	if err := c.LoadFile(filepath.Join(dir, "main.ini")); err != nil {
+		t.Fatal(err)
+	}
+	src, ok := c.Source("db", "host")
+	if !ok || strings.Join(src.Values, ",") != "common,backup" || len(src.Origins) != 2 || src.Secret {
+		t.Fatalf("host %+v", src)
+	}
+	// 多层include从内到外连接
+	want := filepath.Join(dir, "common.ini") + ":3 (included from " +
+		filepath.Join(dir, "mid.ini") + ":1 < " + filepath.Join(dir, "main.ini") + ":1)"
+	if got := src.Origins[1].String(); got != want {
+		t.Errorf("origin %q, want %q", got, want)
+	}
+	if src, _ := c.Source("db", "port"); src.Origins[0].Include != "" || src.Origins[0].Line != 3 {
+		t.Errorf("port %+v", src)
+	}
+	if src, _ := c.Source("db", "user"); src.Origins[0].String() != "env APP_DB_USER" || src.Origins[0].Layer() != "env" {
+		t.Errorf("user %+v", src)
+	}
+	if _, ok := c.Source("db", "none"); ok {
+		t.Error("missing key found")
+	}
+	// 返回的是副本
+	src.Values[0] = "changed"
+	if c.GetValue("db", "host") != "common" {
+		t.Error("Source shares values with the config")
+	}
+}
+
+func TestDebugHandler(t *testing.T) {
+	unsetEnv(t, SecretKeyEnv, SecretKeyFileEnv)
+	dir := writeFiles(t, map[string]string{"pass": "p@ss"})
+	enc, _ := EncryptValue("e@ss", []byte("master"))
+	c := New()
+	c.SetSecretKey([]byte("master"))
+	err := c.LoadString("[db]\nhost = a\npassword = @file:" + filepath.Join(dir, "pass") +
+		"\ntoken = x\ndsn = " + enc + "\n[app]\nname = demo\nnote = a\\nb\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := c.DebugHandler(DebugOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(h http.Handler, query string) string {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/debug/config"+query, nil))
+		body, _ := ioutil.ReadAll(w.Body)
+		return string(body)
+	}
+
+	want := "[app]\nname = demo\t; line 7\nnote = a\\nb\t; line 8\n\n" +
+		"[db]\ndsn = " + Mask + "\t; line 5\nhost = a\t; line 2\npassword = " + Mask + "\t; line 3\ntoken = " + Mask + "\t; line 4\n"
+	if out := get(h, ""); out != want {
+		t.Errorf("text:\n%s\nwant:\n%s", out, want)
+	}
+	for _, s := range []string{"p@ss", "e@ss", "ENC(", "@file:"} {
+		if out := get(h, "?format=json"); strings.Contains(out, s) {
+			t.Errorf("json output contains %q", s)
+		}
+	}
+
+	var out map[string]map[string][]debugValue
+	if err := json.Unmarshal([]byte(get(h, "?format=json&section=db")), &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 || out["db"]["host"][0] != (debugValue{Value: "a", Origin: "line 2", Layer: "file"}) {
+		t.Errorf("json %v", out)
+	}
+	if out["db"]["password"][0].Value != Mask || out["db"]["dsn"][0].Value != Mask {
+		t.Errorf("json secrets %v", out["db"])
+	}
+
+	// 自定义的规则只影响按名字隐藏 secret仍然隐藏
+	h, err = c.DebugHandler(DebugOptions{MaskPattern: `^app\.name$`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out2 := get(h, "")
+	if !strings.Contains(out2, "name = "+Mask) || !strings.Contains(out2, "token = x") || !strings.Contains(out2, "password = "+Mask) {
+		t.Errorf("custom pattern:\n%s", out2)
+	}
+	// 隐藏不影响配置中的值
+	if c.GetValue("db", "password") != "p@ss" || c.GetValue("app", "name") != "demo" {
+		t.Error("handler changed the config")
+	}
+	if _, err := c.DebugHandler(DebugOptions{MaskPattern: "("}); err == nil {
+		t.Error("invalid pattern accepted")
+	}
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Diagnostic 解析时发现的一行有问题的内容
+// 宽松模式下这些行被忽略 可以通过Warnings获取 严格模式下作为ParseErrors返回
+type Diagnostic struct {
+	Origin Origin
+	// 原始的行内容 去掉了首尾空白
+	Text   string
+	Reason string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s: %q", d.Origin, d.Reason, d.Text)
+}
+
+// ParseErrors 严格模式下返回的全部错误
+type ParseErrors []Diagnostic
+
+func (e ParseErrors) Error() string {
+	lines := make([]string, len(e))
+	for i, d := range e {
+		lines[i] = d.String()
+	}
+	return fmt.Sprintf("config: %d parse error(s):\n\t%s", len(e), strings.Join(lines, "\n\t"))
+}
+
+// warn 记录一行有问题的内容
+func (s *snapshot) warn(at Origin, text, reason string) {
+	s.warnings = append(s.warnings, Diagnostic{Origin: at, Text: text, Reason: reason})
+}
+
+// SetStrict 设置严格模式 对之后的Load生效
+// 严格模式下任何有问题的行都会让加载失败 返回ParseErrors
+// 默认的宽松模式保持以前的行为 忽略这些行并记录为警告
+func (c *Config) SetStrict(strict bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.strict = strict
+}
+
+// Warnings 返回最近一次成功加载时被忽略的行
+func (c *Config) Warnings() []Diagnostic {
+	snap := c.snapshot()
+	if snap == nil {
+		return nil
+	}
+	return append([]Diagnostic{}, snap.warnings...)
+}
+
+// check 严格模式下有警告时返回错误
+func (c *Config) check(snap *snapshot) error {
+	c.lock.Lock()
+	strict := c.strict
+	c.lock.Unlock()
+	if strict && len(snap.warnings) > 0 {
+		return ParseErrors(append([]Diagnostic{}, snap.warnings...))
+	}
+	return nil
+}
+
+// SetStrict 设置默认配置的严格模式
+func SetStrict(strict bool) {
+	defConfig.SetStrict(strict)
+}
+
+// Warnings 返回默认配置加载时被忽略的行
+func Warnings() []Diagnostic {
+	return defConfig.Warnings()
+}
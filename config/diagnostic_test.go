@@ -0,0 +1,72 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const badLines = `[db]
+host = a
+port 3306
+= nokey
+[broken
+empty =
+`
+
+func TestWarnings(t *testing.T) {
+	c := mustLoad(t, badLines)
+	if c.GetValue("db", "host") != "a" {
+		t.Error("valid line lost")
+	}
+	want := []struct {
+		line   int
+		reason string
+	}{
+		{3, "missing '='"},
+		{4, "empty key"},
+		{5, "missing ']'"},
+		{6, "empty value"},
+	}
+	warnings := c.Warnings()
+	if len(warnings) != len(want) {
+		t.Fatalf("got %d warnings: %v", len(warnings), warnings)
+	}
+	for i, w := range want {
+		if warnings[i].Origin.Line != w.line || !strings.Contains(warnings[i].Reason, w.reason) {
+			t.Errorf("warning %d: %v", i, warnings[i])
+		}
+	}
+}
+
+func TestStrict(t *testing.T) {
+	c := New()
+	c.SetStrict(true)
+	err := c.LoadString(badLines)
+	var perr ParseErrors
+	if !errors.As(err, &perr) || len(perr) != 4 {
+		t.Fatalf("got %v", err)
+	}
+	if c.GetSection("db") != nil {
+		t.Error("failed strict load replaced the config")
+	}
+}
+
+func TestStrictBeforeSchema(t *testing.T) {
+	// port那一行有问题 严格模式下需要报告这一行 而不是声明中缺少port
+	c := New()
+	c.SetStrict(true)
+	c.SetSchema(&Schema{Sections: []SectionSchema{{
+		Name: "db",
+		Keys: []KeySchema{{Name: "port", Type: TypeInt, Required: true}},
+	}}})
+	err := c.LoadString("[db]\nport 3306\n")
+	var perr ParseErrors
+	if !errors.As(err, &perr) || perr[0].Origin.Line != 2 {
+		t.Fatalf("got %v", err)
+	}
+	c.SetStrict(false)
+	if err := c.LoadString("[db]\nport 3306\n"); !errors.As(err, new(ValidationErrors)) {
+		t.Fatalf("lenient mode: got %v", err)
+	}
+}
@@ -0,0 +1,385 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Document 按行保存的ini文件 保留注释 空行和key的顺序
+// 用于修改部署好的配置文件 没有修改的行原样写回
+type Document struct {
+	lines []*docLine
+	// 使用扩展语法解析和写入值
+	extended bool
+	// 换行符 和原文件保持一致
+	eol string
+	// 原文件最后一行没有换行
+	noeol bool
+}
+
+// docLine 一个逻辑行 续行和heredoc包含多个物理行
+type docLine struct {
+	raw []string
+	// 所在的块 块外面的include等为空
+	sec string
+	// 块的开始
+	header bool
+	// key为空表示注释 空行或者无法识别的行
+	key string
+	// 值前面的部分 如"host = " 修改值时保持不变
+	prefix string
+	value  string
+}
+
+// NewDocument 创建一个空的文档 extended为true时按扩展语法读写值
+func NewDocument(extended bool) *Document {
+	return &Document{extended: extended, eol: "\n"}
+}
+
+// ParseDocument 从r读取文档 extended为true时按扩展语法解析
+func ParseDocument(r io.Reader, extended bool) (*Document, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	d := NewDocument(extended)
+	if bytes.Contains(data, []byte("\r\n")) {
+		d.eol = "\r\n"
+	}
+	d.noeol = len(data) > 0 && data[len(data)-1] != '\n'
+	lr := &lineReader{r: bufio.NewReader(bytes.NewReader(data)), keep: true}
+	var sec string
+	for {
+		lr.raw = nil
+		line, ok, err := lr.next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		lineno := lr.lineno
+		if extended {
+			if line, err = joinContinuation(lr, line); err != nil {
+				return nil, err
+			}
+		}
+		dl := &docLine{sec: sec}
+		d.lines = append(d.lines, dl)
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed[0] == ';' || trimmed[0] == '#' {
+			dl.raw = lr.raw
+			continue
+		}
+		if extended && trimmed[0] == '[' {
+			trimmed = stripInlineComment(trimmed)
+		}
+		if trimmed[0] == '[' && trimmed[len(trimmed)-1] == ']' {
+			sec, _ = parseHeader(trimmed[1:len(trimmed)-1], extended)
+			dl.sec, dl.header, dl.raw = sec, true, lr.raw
+			continue
+		}
+		eq := strings.IndexByte(line, '=')
+		if eq < 0 {
+			dl.raw = lr.raw
+			continue
+		}
+		key := strings.TrimSpace(line[:eq])
+		if extended {
+			key = strings.TrimSpace(strings.TrimSuffix(key, "+"))
+		}
+		rest := line[eq+1:]
+		val, explicit := strings.TrimSpace(rest), false
+		if extended {
+			if val, explicit, err = parseValue(lr, val); err != nil {
+				return nil, fmt.Errorf("config: line %d: %v", lineno, err)
+			}
+		}
+		dl.raw = lr.raw
+		// 和解析时一样 空的key和值被忽略
+		if key == "" || val == "" && !explicit {
+			continue
+		}
+		dl.key, dl.value = key, val
+		dl.prefix = line[:len(line)-len(strings.TrimLeft(rest, " \t"))]
+	}
+	return d, nil
+}
+
+// LoadDocument 从文件读取文档
+func LoadDocument(name string, extended bool) (*Document, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseDocument(f, extended)
+}
+
+// Sections 按出现的顺序返回全部块名 重复出现的块只返回一次
+func (d *Document) Sections() []string {
+	secs := []string{}
+	seen := make(map[string]bool)
+	for _, dl := range d.lines {
+		if dl.header && !seen[dl.sec] {
+			seen[dl.sec] = true
+			secs = append(secs, dl.sec)
+		}
+	}
+	return secs
+}
+
+// Keys 按出现的顺序返回块中的全部key
+func (d *Document) Keys(sec string) []string {
+	keys := []string{}
+	seen := make(map[string]bool)
+	for _, dl := range d.lines {
+		if dl.key != "" && dl.sec == sec && !seen[dl.key] {
+			seen[dl.key] = true
+			keys = append(keys, dl.key)
+		}
+	}
+	return keys
+}
+
+// Get 返回key的全部值 不存在时返回nil
+func (d *Document) Get(sec, key string) []string {
+	var vals []string
+	for _, dl := range d.lines {
+		if dl.key == key && dl.sec == sec {
+			vals = append(vals, dl.value)
+		}
+	}
+	return vals
+}
+
+// Set 设置key的值 多个值表示数组
+// 已有的行原地修改 值相同的行保持不变 多余的行删除
+// key不存在时添加到块中最后一个key的后面 块不存在时添加到文件末尾
+func (d *Document) Set(sec, key string, values ...string) error {
+	if len(values) == 0 {
+		d.Delete(sec, key)
+		return nil
+	}
+	if err := checkDocName(sec, key); err != nil {
+		return err
+	}
+	raws := make([]string, len(values))
+	for i, v := range values {
+		raw, err := d.encodeValue(v)
+		if err != nil {
+			return fmt.Errorf("config: [%s] %s: %v", sec, key, err)
+		}
+		raws[i] = raw
+	}
+	i := 0
+	lines := d.lines[:0:0]
+	for _, dl := range d.lines {
+		if dl.key != key || dl.sec != sec {
+			lines = append(lines, dl)
+			continue
+		}
+		if i >= len(values) {
+			continue
+		}
+		if dl.value != values[i] {
+			dl.value = values[i]
+			dl.raw = []string{dl.prefix + raws[i]}
+		}
+		lines = append(lines, dl)
+		i++
+	}
+	d.lines = lines
+	for ; i < len(values); i++ {
+		d.insert(sec, key, values[i], raws[i])
+	}
+	return nil
+}
+
+// Add 在key已有的值后面追加一个值 key不存在时和Set相同
+func (d *Document) Add(sec, key, value string) error {
+	if err := checkDocName(sec, key); err != nil {
+		return err
+	}
+	raw, err := d.encodeValue(value)
+	if err != nil {
+		return fmt.Errorf("config: [%s] %s: %v", sec, key, err)
+	}
+	d.insert(sec, key, value, raw)
+	return nil
+}
+
+// Delete 删除key的全部值 返回key是否存在
+func (d *Document) Delete(sec, key string) bool {
+	found := false
+	lines := d.lines[:0:0]
+	for _, dl := range d.lines {
+		if dl.key == key && dl.sec == sec {
+			found = true
+			continue
+		}
+		lines = append(lines, dl)
+	}
+	d.lines = lines
+	return found
+}
+
+// DeleteSection 删除块和块中的全部内容 返回块是否存在
+func (d *Document) DeleteSection(sec string) bool {
+	found := false
+	lines := d.lines[:0:0]
+	for _, dl := range d.lines {
+		if dl.sec == sec && sec != "" {
+			found = true
+			continue
+		}
+		lines = append(lines, dl)
+	}
+	d.lines = lines
+	return found
+}
+
+// insert 添加一行 位置在同名key的最后一个值后面 或者块中最后一个key的后面
+func (d *Document) insert(sec, key, value, raw string) {
+	dl := &docLine{sec: sec, key: key, prefix: key + " = ", value: value}
+	dl.raw = []string{dl.prefix + raw}
+	at, keyat := -1, -1
+	for i, l := range d.lines {
+		if l.sec != sec {
+			continue
+		}
+		if l.header || l.key != "" {
+			at = i
+		}
+		if l.key == key {
+			keyat = i
+		}
+	}
+	if keyat >= 0 {
+		at = keyat
+	}
+	if at < 0 && sec == "" {
+		// 块外面的key放在第一个块前面
+		d.lines = append([]*docLine{dl}, d.lines...)
+		return
+	}
+	if at < 0 {
+		if n := len(d.lines); n > 0 && strings.TrimSpace(strings.Join(d.lines[n-1].raw, "")) != "" {
+			d.lines = append(d.lines, &docLine{sec: sec, raw: []string{""}})
+		}
+		d.lines = append(d.lines, &docLine{sec: sec, header: true, raw: []string{"[" + sec + "]"}}, dl)
+		return
+	}
+	d.lines = append(d.lines, nil)
+	copy(d.lines[at+2:], d.lines[at+1:])
+	d.lines[at+1] = dl
+}
+
+func checkDocName(sec, key string) error {
+	if strings.ContainsAny(sec, "]\r\n") {
+		return fmt.Errorf("config: invalid section name %q", sec)
+	}
+	if key == "" || strings.ContainsAny(key, "=\r\n") || strings.HasSuffix(key, "+") ||
+		strings.TrimSpace(key) != key || strings.IndexAny(key[:1], "[;#") == 0 {
+		return fmt.Errorf("config: invalid key %q", key)
+	}
+	return nil
+}
+
+// encodeValue 把值写成文件中的形式 扩展语法下需要时加上双引号
+func (d *Document) encodeValue(v string) (string, error) {
+	plain := v != "" && strings.TrimSpace(v) == v && !strings.ContainsAny(v, "\r\n")
+	if !d.extended {
+		if !plain {
+			return "", errors.New("value can not be empty, multi-line or have surrounding spaces without extended syntax")
+		}
+		return v, nil
+	}
+	if plain && stripInlineComment(v) == v && !trailingBackslash(v) &&
+		!strings.ContainsAny(v[:1], "\"'") && !strings.HasPrefix(v, "<<") {
+		return v, nil
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range v {
+		switch r {
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(&b, `\x%02x`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String(), nil
+}
+
+// WriteTo 写出文档 没有修改的行和原文件完全相同
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	var n int64
+	for i, dl := range d.lines {
+		for j, raw := range dl.raw {
+			m, _ := bw.WriteString(raw)
+			n += int64(m)
+			if d.noeol && i == len(d.lines)-1 && j == len(dl.raw)-1 {
+				break
+			}
+			m, _ = bw.WriteString(d.eol)
+			n += int64(m)
+		}
+	}
+	return n, bw.Flush()
+}
+
+// String 返回文档的内容
+func (d *Document) String() string {
+	var b strings.Builder
+	d.WriteTo(&b)
+	return b.String()
+}
+
+// SaveFile 写入文件 先写临时文件再改名 不会留下写了一半的文件
+// 文件已经存在时保持原来的权限
+func (d *Document) SaveFile(name string) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(name); err == nil {
+		mode = info.Mode().Perm()
+	}
+	f, err := ioutil.TempFile(filepath.Dir(name), "."+filepath.Base(name)+".tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	_, err = d.WriteTo(f)
+	if err == nil {
+		err = f.Chmod(mode)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, name)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+const docFile = "; top comment\r\n" +
+	"[db]\r\n" +
+	"host = a ; keep\r\n" +
+	"\r\n" +
+	"# port comment\r\n" +
+	"port = 3306\r\n" +
+	"[cache]\r\n" +
+	"size = 1"
+
+func TestDocumentRoundTrip(t *testing.T) {
+	d, err := ParseDocument(strings.NewReader(docFile), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.String() != docFile {
+		t.Fatalf("unmodified document changed:\n%q", d.String())
+	}
+	if got := strings.Join(d.Sections(), ","); got != "db,cache" {
+		t.Errorf("sections %s", got)
+	}
+	if got := strings.Join(d.Keys("db"), ","); got != "host,port" {
+		t.Errorf("keys %s", got)
+	}
+}
+
+func TestDocumentEdit(t *testing.T) {
+	d, err := ParseDocument(strings.NewReader(docFile), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Set("db", "port", "3307"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Add("db", "slave", "s1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Set("log", "level", "info"); err != nil {
+		t.Fatal(err)
+	}
+	if !d.Delete("cache", "size") || d.Delete("cache", "size") {
+		t.Error("Delete result")
+	}
+	want := "; top comment\r\n" +
+		"[db]\r\n" +
+		"host = a ; keep\r\n" +
+		"\r\n" +
+		"# port comment\r\n" +
+		"port = 3307\r\n" +
+		"slave = s1\r\n" +
+		"[cache]\r\n" +
+		"\r\n" +
+		"[log]\r\n" +
+		"level = info"
+	if d.String() != want {
+		t.Fatalf("got\n%q\nwant\n%q", d.String(), want)
+	}
+	// 写出的内容可以按原来的方式加载
+	c := mustLoad(t, d.String())
+	if c.GetValue("db", "port") != "3307" || c.GetValue("log", "level") != "info" {
+		t.Errorf("reload: %v", c.GetSection("db"))
+	}
+}
+
+func TestDocumentEncode(t *testing.T) {
+	d := NewDocument(false)
+	for _, v := range []string{"", " a", "a\nb"} {
+		if err := d.Set("s", "k", v); err == nil {
+			t.Errorf("%q accepted without extended syntax", v)
+		}
+	}
+	for _, key := range []string{"a+", "a=b", " a", "[a", ""} {
+		if err := d.Set("s", key, "v"); err == nil {
+			t.Errorf("key %q accepted", key)
+		}
+	}
+
+	d = NewDocument(true)
+	values := []string{"", " a ", "a\nb", `say "hi" ; x`, "'q'", "<<EOF", `c:\`}
+	if err := d.Set("s", "k", values...); err != nil {
+		t.Fatal(err)
+	}
+	c := New()
+	c.SetExtendedSyntax(true)
+	if err := c.LoadString(d.String()); err != nil {
+		t.Fatal(err)
+	}
+	got := c.GetValueSlice("s", "k")
+	if strings.Join(got, "|") != strings.Join(values, "|") {
+		t.Errorf("got %q\nfrom %s", got, d.String())
+	}
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"bufio"
+	"io"
+	"sort"
+)
+
+// Dump 按ini格式输出当前生效的内容 块名和key按字母排序
+// 加密保存的值输出为Mask
+func (c *Config) Dump(w io.Writer) error {
+	snap := c.snapshot()
+	if snap == nil {
+		return nil
+	}
+	bw := bufio.NewWriter(w)
+	secs := make([]string, 0, len(snap.file))
+	for sec := range snap.file {
+		secs = append(secs, sec)
+	}
+	sort.Strings(secs)
+	for i, sec := range secs {
+		if i > 0 {
+			bw.WriteString("\n")
+		}
+		// 名字为空的块是顶层的key 写在所有块的前面
+		if sec != "" {
+			bw.WriteString("[" + sec + "]\n")
+		}
+		keys := make([]string, 0, len(snap.file[sec]))
+		for key := range snap.file[sec] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			for _, val := range snap.file[sec][key] {
+				if snap.secrets[sec][key] {
+					val = Mask
+				}
+				bw.WriteString(key + " = " + val + "\n")
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// Dump 输出默认配置当前生效的内容
+func Dump(w io.Writer) error {
+	return defConfig.Dump(w)
+}
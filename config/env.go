@@ -0,0 +1,178 @@
+package config
+
+import (
+	"os"
+	"sort"
+	"strings"
+)
+
+// EnvOptions 环境变量相关的设置 在Load之前通过SetEnv设置
+type EnvOptions struct {
+	// Expand 展开值中的${VAR}和${VAR:-default}
+	// 名字中带.的${sec.key}不是环境变量 保持原样 $${写作字面的${
+	Expand bool
+	// Prefix 覆盖层使用的前缀 如APP 为空时不启用覆盖
+	// 启用后名字为Name(sec, key)的环境变量会替换文件中的值
+	Prefix string
+	// Name 环境变量的命名规则 默认为EnvName
+	// 自定义Name时只覆盖文件中已有的key 不再按PREFIX_SECTION_前缀添加文件中没有的key
+	Name func(prefix, sec, key string) string
+	// Lookup 读取环境变量 默认为os.LookupEnv 测试时可以替换
+	Lookup func(name string) (string, bool)
+	// Environ 列出全部环境变量 用于查找文件中不存在的key 默认为os.Environ
+	Environ func() []string
+
+	// 使用自定义的Name
+	customName bool
+}
+
+// EnvName 默认的环境变量命名规则 PREFIX_SECTION_KEY
+// 全部大写 字母数字以外的字符替换为_ 如[db.master] max-conn -> APP_DB_MASTER_MAX_CONN
+func EnvName(prefix, sec, key string) string {
+	return envWord(prefix) + "_" + envWord(sec) + "_" + envWord(key)
+}
+
+func envWord(s string) string {
+	b := []byte(strings.ToUpper(s))
+	for i, c := range b {
+		if !(c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+// SetEnv 设置环境变量的展开和覆盖 对之后的Load生效
+func (c *Config) SetEnv(opts EnvOptions) {
+	opts.customName = opts.Name != nil
+	if opts.Name == nil {
+		opts.Name = EnvName
+	}
+	if opts.Lookup == nil {
+		opts.Lookup = os.LookupEnv
+	}
+	if opts.Environ == nil {
+		opts.Environ = os.Environ
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.env = &opts
+}
+
+// SetEnv 设置默认配置的环境变量展开和覆盖
+func SetEnv(opts EnvOptions) {
+	defConfig.SetEnv(opts)
+}
+
+// expand 展开值中的环境变量
+func (o *EnvOptions) expand(snap *snapshot) {
+	if !o.Expand {
+		return
+	}
+	for _, values := range snap.file {
+		for _, list := range values {
+			for i := range list {
+				list[i] = expandEnv(list[i], o.Lookup)
+			}
+		}
+	}
+}
+
+// override 应用环境变量的覆盖层
+func (o *EnvOptions) override(snap *snapshot) {
+	if o.Prefix == "" {
+		return
+	}
+	secs := make([]string, 0, len(snap.file))
+	for sec := range snap.file {
+		secs = append(secs, sec)
+	}
+	sort.Strings(secs)
+	used := make(map[string]bool)
+	for _, sec := range secs {
+		for key := range snap.file[sec] {
+			name := o.Name(o.Prefix, sec, key)
+			if val, ok := o.Lookup(name); ok {
+				snap.set(sec, key, []string{val}, Origin{Env: name})
+				used[name] = true
+			}
+		}
+	}
+	// 文件中没有的key 按块名匹配环境变量 剩余部分作为小写的key
+	// 自定义的Name不能反推出块名和key 只使用默认规则时查找
+	if o.customName {
+		return
+	}
+	prefix := envWord(o.Prefix) + "_"
+	for _, kv := range o.Environ() {
+		eq := strings.IndexByte(kv, '=')
+		if eq < 0 || !strings.HasPrefix(kv, prefix) {
+			continue
+		}
+		name := kv[:eq]
+		if used[name] {
+			continue
+		}
+		val, ok := o.Lookup(name)
+		if !ok {
+			continue
+		}
+		// 多个块匹配时使用最长的块名 如APP_DB_MASTER_X 优先匹配[db.master]
+		best, bestlen := "", 0
+		for _, sec := range secs {
+			secprefix := prefix + envWord(sec) + "_"
+			if strings.HasPrefix(name, secprefix) && len(name) > len(secprefix) && len(secprefix) > bestlen {
+				best, bestlen = sec, len(secprefix)
+			}
+		}
+		if bestlen == 0 {
+			continue
+		}
+		key := strings.ToLower(name[bestlen:])
+		if _, has := snap.file[best][key]; !has {
+			snap.set(best, key, []string{val}, Origin{Env: name})
+		}
+	}
+}
+
+// expandEnv 展开s中的${VAR}和${VAR:-default}
+func expandEnv(s string, lookup func(string) (string, bool)) string {
+	if !strings.Contains(s, "${") {
+		return s
+	}
+	var b strings.Builder
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			b.WriteString(s)
+			return b.String()
+		}
+		if i > 0 && s[i-1] == '$' {
+			// $${ 转义为字面的${
+			b.WriteString(s[:i-1])
+			b.WriteString("${")
+			s = s[i+2:]
+			continue
+		}
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 {
+			b.WriteString(s)
+			return b.String()
+		}
+		b.WriteString(s[:i])
+		inner := s[i+2 : i+end]
+		name, def, hasDef := inner, "", false
+		if j := strings.Index(inner, ":-"); j >= 0 {
+			name, def, hasDef = inner[:j], inner[j+2:], true
+		}
+		if strings.ContainsRune(name, '.') || name == "" {
+			// 块引用或者不合法的名字 保持原样
+			b.WriteString(s[i : i+end+1])
+		} else if val, ok := lookup(name); ok && (val != "" || !hasDef) {
+			b.WriteString(val)
+		} else {
+			b.WriteString(def)
+		}
+		s = s[i+end+1:]
+	}
+}
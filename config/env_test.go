@@ -0,0 +1,115 @@
+package config
+
+import "testing"
+
+// fakeEnv 使用map代替进程的环境变量
+func fakeEnv(opts EnvOptions, env map[string]string) EnvOptions {
+	opts.Lookup = func(name string) (string, bool) {
+		val, ok := env[name]
+		return val, ok
+	}
+	opts.Environ = func() []string {
+		list := make([]string, 0, len(env))
+		for k, v := range env {
+			list = append(list, k+"="+v)
+		}
+		return list
+	}
+	return opts
+}
+
+func TestEnvExpand(t *testing.T) {
+	c := New()
+	c.SetEnv(fakeEnv(EnvOptions{Expand: true}, map[string]string{"HOST": "db1", "EMPTY": ""}))
+	err := c.LoadString(`[db]
+dsn = root@${HOST}:${PORT:-3306}
+empty = [${EMPTY:-def}]
+literal = $${HOST}
+ref = ${db.dsn}
+open = ${HOST
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]string{
+		"dsn":     "root@db1:3306",
+		"empty":   "[def]",
+		"literal": "${HOST}",
+		"open":    "${HOST",
+	}
+	for key, want := range tests {
+		if got := c.GetValue("db", key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestEnvOverride(t *testing.T) {
+	c := New()
+	c.SetEnv(fakeEnv(EnvOptions{Prefix: "APP"}, map[string]string{
+		"APP_DB_HOST":            "envhost",
+		"APP_DB_MASTER_MAX_CONN": "50",
+		"APP_DB_NEWKEY":          "added",
+		"APP_NOSECTION_KEY":      "ignored",
+		"OTHER_DB_HOST":          "ignored",
+	}))
+	err := c.LoadString(`[db]
+host = filehost
+host = second
+port = 3306
+[db.master]
+max-conn = 10
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vals := c.GetValueSlice("db", "host"); len(vals) != 1 || vals[0] != "envhost" {
+		t.Errorf("host = %v", vals)
+	}
+	if got := c.GetValue("db.master", "max-conn"); got != "50" {
+		t.Errorf("max-conn = %q", got)
+	}
+	if got := c.GetValue("db", "newkey"); got != "added" {
+		t.Errorf("newkey = %q", got)
+	}
+	if c.GetSection("nosection") != nil {
+		t.Error("env created a section")
+	}
+	o, _ := c.Origin("db", "host")
+	if o.Env != "APP_DB_HOST" || o.Layer() != "env" {
+		t.Errorf("origin %+v", o)
+	}
+	if o, _ := c.Origin("db", "port"); o.Layer() != "file" || o.Line != 4 {
+		t.Errorf("port origin %+v", o)
+	}
+}
+
+func TestEnvName(t *testing.T) {
+	if got := EnvName("app", "db.master", "max-conn"); got != "APP_DB_MASTER_MAX_CONN" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestEnvOverrideCustomName(t *testing.T) {
+	c := New()
+	c.SetEnv(fakeEnv(EnvOptions{
+		Prefix: "APP",
+		Name: func(prefix, sec, key string) string {
+			return prefix + "__" + sec + "__" + key
+		},
+	}, map[string]string{
+		"APP__db__host": "envhost",
+		"APP_DB_HOST":   "default-name",
+		"APP_DB_NEWKEY": "default-name",
+	}))
+	if err := c.LoadString("[db]\nhost = filehost\n"); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.GetValue("db", "host"); got != "envhost" {
+		t.Errorf("host = %q", got)
+	}
+	// 自定义规则时不按默认的PREFIX_SECTION_前缀添加key
+	if vals := c.GetSection("db"); len(vals) != 1 {
+		t.Errorf("db = %v", vals)
+	}
+}
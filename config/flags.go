@@ -0,0 +1,175 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// 命令行参数作为最上层 覆盖文件和环境变量中的值
+//
+//	config.LoadFile("app.ini")
+//	config.BindFlags(schema)
+//	flag.Parse()
+//	// ./app --db.dsn=... --db.slave=a --db.slave=b
+//
+// 参数名为"块名.key" 重复的参数组成数组 替换文件中的全部值
+// --help输出每个参数当前生效的值和来源
+
+// flagValue 一个key对应的命令行参数
+type flagValue struct {
+	c      *Config
+	sec    string
+	key    string
+	typ    ValueType
+	hasset bool
+}
+
+func (v *flagValue) String() string {
+	if v == nil || v.c == nil {
+		return ""
+	}
+	return strings.Join(v.c.GetValueSlice(v.sec, v.key), ",")
+}
+
+// Set 第一次出现时替换文件中的值 之后出现的追加为数组
+func (v *flagValue) Set(s string) error {
+	prev := v.c.setFlag(v.sec, v.key, s, !v.hasset)
+	if err := v.c.refresh(); err != nil {
+		v.c.restoreFlag(v.sec, v.key, prev)
+		return err
+	}
+	v.hasset = true
+	return nil
+}
+
+func (v *flagValue) Get() interface{} {
+	return v.c.GetValueSlice(v.sec, v.key)
+}
+
+// IsBoolFlag bool类型的key可以写作--sec.key 不需要值
+func (v *flagValue) IsBoolFlag() bool {
+	return v.typ == TypeBool
+}
+
+// setFlag 记录命令行参数的值 返回之前的值
+func (c *Config) setFlag(sec, key, val string, replace bool) []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.flags == nil {
+		c.flags = make(map[string]map[string][]string)
+	}
+	if c.flags[sec] == nil {
+		c.flags[sec] = make(map[string][]string)
+	}
+	prev := c.flags[sec][key]
+	if replace {
+		c.flags[sec][key] = []string{val}
+	} else {
+		c.flags[sec][key] = append(append([]string{}, prev...), val)
+	}
+	return prev
+}
+
+func (c *Config) restoreFlag(sec, key string, prev []string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if prev == nil {
+		delete(c.flags[sec], key)
+	} else {
+		c.flags[sec][key] = prev
+	}
+}
+
+// applyFlags 把命令行参数的值写入snap
+func (c *Config) applyFlags(snap *snapshot) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for sec, values := range c.flags {
+		for key, vals := range values {
+			snap.set(sec, key, append([]string{}, vals...), Origin{Flag: "--" + sec + "." + key})
+		}
+	}
+}
+
+// BindFlag 为[sec] key注册命令行参数--sec.key
+func (c *Config) BindFlag(fs *flag.FlagSet, sec, key, usage string) {
+	c.bindFlag(fs, sec, KeySchema{Name: key, Help: usage})
+}
+
+func (c *Config) bindFlag(fs *flag.FlagSet, sec string, ks KeySchema) {
+	fs.Var(&flagValue{c: c, sec: sec, key: ks.Name, typ: ks.Type}, sec+"."+ks.Name, ks.Help)
+}
+
+// BindFlags 为schema中声明的key注册命令行参数 并替换fs的帮助信息
+// 块名使用通配符的声明和已经废弃的key不注册
+func (c *Config) BindFlags(fs *flag.FlagSet, schema *Schema) {
+	for _, sec := range schema.Sections {
+		if strings.ContainsAny(sec.Name, "*?[\\") {
+			continue
+		}
+		for _, ks := range sec.Keys {
+			if ks.Deprecated == "" {
+				c.bindFlag(fs, sec.Name, ks)
+			}
+		}
+	}
+	fs.Usage = func() {
+		if fs.Name() == "" {
+			fmt.Fprintf(fs.Output(), "Usage:\n")
+		} else {
+			fmt.Fprintf(fs.Output(), "Usage of %s:\n", fs.Name())
+		}
+		c.PrintFlags(fs)
+	}
+}
+
+// PrintFlags 和flag.PrintDefaults类似 配置对应的参数输出当前生效的值和来源
+func (c *Config) PrintFlags(fs *flag.FlagSet) {
+	out := fs.Output()
+	fs.VisitAll(func(f *flag.Flag) {
+		name, usage := flag.UnquoteUsage(f)
+		fv, ok := f.Value.(*flagValue)
+		if !ok || fv.c != c {
+			line := "  -" + f.Name
+			if name != "" {
+				line += " " + name
+			}
+			line += "\n    \t" + strings.Replace(usage, "\n", "\n    \t", -1)
+			if f.DefValue != "" && f.DefValue != "false" && f.DefValue != "0" {
+				line += fmt.Sprintf(" (default %q)", f.DefValue)
+			}
+			fmt.Fprintln(out, line)
+			return
+		}
+		line := "  --" + f.Name
+		if fv.typ != TypeBool {
+			line += " " + fv.typ.String()
+		}
+		if usage != "" {
+			line += "\n    \t" + strings.Replace(usage, "\n", "\n    \t", -1)
+		}
+		vals := c.GetValueSlice(fv.sec, fv.key)
+		if len(vals) == 0 {
+			line += "\n    \t(not set)"
+		} else {
+			current := strings.Join(vals, ", ")
+			if c.IsSecret(fv.sec, fv.key) {
+				current = Mask
+			}
+			at, _ := c.Origin(fv.sec, fv.key)
+			line += fmt.Sprintf("\n    \t(current: %s, from %s)", current, at)
+		}
+		fmt.Fprintln(out, line)
+	})
+}
+
+// BindFlag 为默认配置的[sec] key注册命令行参数
+func BindFlag(sec, key, usage string) {
+	defConfig.BindFlag(flag.CommandLine, sec, key, usage)
+}
+
+// BindFlags 为默认配置注册schema中声明的命令行参数 使用flag.CommandLine
+func BindFlags(schema *Schema) {
+	defConfig.BindFlags(flag.CommandLine, schema)
+}
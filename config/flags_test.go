@@ -0,0 +1,67 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestBindFlags(t *testing.T) {
+	c := mustLoad(t, "[db]\ndsn = root@file\nslave = f1\nmaxconn = 10\n")
+	fs := flag.NewFlagSet("app", flag.ContinueOnError)
+	var out bytes.Buffer
+	fs.SetOutput(&out)
+	c.BindFlags(fs, testSchema())
+	c.BindFlag(fs, "log", "level", "log level")
+	err := fs.Parse([]string{"--db.dsn=root@flag", "--db.slave=a", "--db.slave=b", "--log.level=info"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.GetValue("db", "dsn") != "root@flag" || c.GetValue("log", "level") != "info" {
+		t.Errorf("db = %v", c.GetSection("db"))
+	}
+	if vals := c.GetValueSlice("db", "slave"); strings.Join(vals, ",") != "a,b" {
+		t.Errorf("slave = %v", vals)
+	}
+	if o, _ := c.Origin("db", "dsn"); o.Layer() != "flag" || o.Flag != "--db.dsn" {
+		t.Errorf("origin %+v", o)
+	}
+	if fs.Lookup("db.oldconn") != nil || fs.Lookup("servers.*.addr") != nil {
+		t.Error("deprecated or wildcard key registered")
+	}
+
+	// 参数在重新加载文件后仍然生效
+	if err := c.LoadString("[db]\ndsn = root@new\nmaxconn = 20\n"); err != nil {
+		t.Fatal(err)
+	}
+	if c.GetValue("db", "dsn") != "root@flag" || c.GetValue("db", "maxconn") != "20" {
+		t.Errorf("after reload: %v", c.GetSection("db"))
+	}
+
+	fs.Usage()
+	if !strings.Contains(out.String(), "(current: root@flag, from flag --db.dsn)") {
+		t.Errorf("usage:\n%s", out.String())
+	}
+}
+
+func TestBindFlagInvalid(t *testing.T) {
+	schema := testSchema()
+	c := New()
+	c.SetSchema(schema)
+	if err := c.LoadString("[db]\ndsn = root@file\nmaxconn = 10\n"); err != nil {
+		t.Fatal(err)
+	}
+	fs := flag.NewFlagSet("app", flag.ContinueOnError)
+	fs.SetOutput(new(bytes.Buffer))
+	c.BindFlags(fs, schema)
+	if err := fs.Parse([]string{"--db.maxconn=5000"}); err == nil {
+		t.Fatal("invalid flag accepted")
+	}
+	if c.GetValue("db", "maxconn") != "10" {
+		t.Error("invalid flag changed the config")
+	}
+	if err := c.LoadString("[db]\ndsn = root@file\nmaxconn = 30\n"); err != nil {
+		t.Fatalf("rejected flag still applied: %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Decoder 把一种格式的内容解析为嵌套的map
+// 对象和表对应map[string]interface{} 数组对应[]interface{}
+type Decoder func(r io.Reader) (map[string]interface{}, error)
+
+var formats = struct {
+	sync.RWMutex
+	m map[string]Decoder
+}{m: map[string]Decoder{".json": decodeJSON}}
+
+// RegisterFormat 注册一种格式 ext为包括.的扩展名 如".toml"
+// 加载文件时按扩展名选择格式 没有注册的扩展名按ini解析
+// toml和yaml在子包中注册 使用时导入 _ "github.com/go-irain/tools/config/toml"
+func RegisterFormat(ext string, dec Decoder) {
+	formats.Lock()
+	defer formats.Unlock()
+	formats.m[strings.ToLower(ext)] = dec
+}
+
+func lookupFormat(ext string) Decoder {
+	formats.RLock()
+	defer formats.RUnlock()
+	return formats.m[strings.ToLower(ext)]
+}
+
+// LoadFormat 按ext指定的格式从r加载 ext为空或者".ini"时按ini解析
+func (c *Config) LoadFormat(r io.Reader, ext string) error {
+	return c.loadWith(func() (*snapshot, error) {
+		return parseFormat(r, ext, "", c.parseOptions())
+	}, false)
+}
+
+// parseFormat 按扩展名选择格式解析 name用于记录来源
+func parseFormat(r io.Reader, ext, name string, opts parseOptions) (*snapshot, error) {
+	dec := lookupFormat(ext)
+	if dec == nil {
+		return parse(r, name, opts)
+	}
+	tree, err := dec(r)
+	if err != nil {
+		if name != "" {
+			return nil, fmt.Errorf("config: %s: %v", name, err)
+		}
+		return nil, fmt.Errorf("config: %v", err)
+	}
+	snap := newSnapshot()
+	if name != "" {
+		snap.addFile(name)
+	}
+	flatten(snap, "", tree, Origin{File: name})
+	return snap, nil
+}
+
+// flatten 把嵌套的map转换为块
+// 顶层的标量放在名字为空的块中 嵌套的表使用.连接的块名 如[db.master]
+// 元素是表的数组按下标展开 如[[servers]]对应[servers.0] [servers.1]
+func flatten(snap *snapshot, sec string, tree map[string]interface{}, at Origin) {
+	keys := make([]string, 0, len(tree))
+	for key := range tree {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		switch v := tree[key].(type) {
+		case map[string]interface{}:
+			name := joinSection(sec, key)
+			snap.addSection(name, at)
+			flatten(snap, name, v, at)
+		case []interface{}:
+			if tables, ok := tableArray(v); ok {
+				for i, t := range tables {
+					name := joinSection(sec, key) + "." + strconv.Itoa(i)
+					snap.addSection(name, at)
+					flatten(snap, name, t, at)
+				}
+				continue
+			}
+			for _, e := range v {
+				if s, ok := formatScalar(e); ok {
+					snap.add(sec, key, s, at)
+				}
+			}
+		default:
+			if s, ok := formatScalar(v); ok {
+				snap.add(sec, key, s, at)
+			}
+		}
+	}
+}
+
+func joinSection(sec, key string) string {
+	if sec == "" {
+		return key
+	}
+	return sec + "." + key
+}
+
+// tableArray 数组的元素是否全部是表
+func tableArray(list []interface{}) ([]map[string]interface{}, bool) {
+	if len(list) == 0 {
+		return nil, false
+	}
+	tables := make([]map[string]interface{}, len(list))
+	for i, e := range list {
+		t, ok := e.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		tables[i] = t
+	}
+	return tables, true
+}
+
+// formatScalar 把标量转换为字符串 null忽略 其他复杂的值使用json表示
+func formatScalar(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case nil:
+		return "", false
+	case string:
+		return v, true
+	case bool:
+		return strconv.FormatBool(v), true
+	case json.Number:
+		return v.String(), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), true
+	case int:
+		return strconv.Itoa(v), true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case uint64:
+		return strconv.FormatUint(v, 10), true
+	case time.Time:
+		return v.Format(time.RFC3339Nano), true
+	case fmt.Stringer:
+		return v.String(), true
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v), true
+	}
+	return string(data), true
+}
+
+func decodeJSON(r io.Reader) (map[string]interface{}, error) {
+	dec := json.NewDecoder(r)
+	// 保留数字的原样 避免大整数变成浮点数
+	dec.UseNumber()
+	var tree map[string]interface{}
+	if err := dec.Decode(&tree); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const jsonFile = `{
+	"name": "app",
+	"db": {
+		"port": 3306,
+		"ratio": 0.5,
+		"debug": true,
+		"host": ["a", "b"],
+		"none": null,
+		"master": {"dsn": "root@tcp"}
+	},
+	"servers": [{"addr": "s0"}, {"addr": "s1"}]
+}`
+
+func TestLoadJSON(t *testing.T) {
+	c := New()
+	if err := c.LoadFormat(strings.NewReader(jsonFile), ".json"); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		sec, key, want string
+	}{
+		{"", "name", "app"},
+		{"db", "port", "3306"},
+		{"db", "ratio", "0.5"},
+		{"db", "debug", "true"},
+		{"db", "none", ""},
+		{"db.master", "dsn", "root@tcp"},
+		{"servers.1", "addr", "s1"},
+	}
+	for _, tt := range tests {
+		if got := c.GetValue(tt.sec, tt.key); got != tt.want {
+			t.Errorf("[%s] %s = %q, want %q", tt.sec, tt.key, got, tt.want)
+		}
+	}
+	if vals := c.GetValueSlice("db", "host"); strings.Join(vals, ",") != "a,b" {
+		t.Errorf("host = %v", vals)
+	}
+	if err := New().LoadFormat(strings.NewReader("{"), ".json"); err == nil {
+		t.Error("bad json accepted")
+	}
+}
+
+func TestLoadFormatByExt(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"base.ini":  "[db]\nhost = a\nport = 1\n",
+		"prod.JSON": `{"db": {"host": "b"}}`,
+	})
+	c := New()
+	if err := c.LoadFiles(filepath.Join(dir, "base.ini"), filepath.Join(dir, "prod.JSON")); err != nil {
+		t.Fatal(err)
+	}
+	if c.GetValue("db", "host") != "b" || c.GetValue("db", "port") != "1" {
+		t.Errorf("db = %v", c.GetSection("db"))
+	}
+	if o, _ := c.Origin("db", "host"); o.File != filepath.Join(dir, "prod.JSON") || o.Line != 0 {
+		t.Errorf("origin %+v", o)
+	}
+}
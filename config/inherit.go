@@ -0,0 +1,223 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 块继承和块引用
+//
+//	[db_master]
+//	host = 10.0.0.1
+//	port = 3306
+//	opts = a
+//
+//	[db_slave : db_master]      ; 没有的key从db_master继承
+//	host = 10.0.0.2             ; 替换继承的值
+//	opts += b                   ; 追加到继承的数组后面 结果为a b
+//	master = ${db_master.host}  ; 引用其他块的值 ${sec.key:-default}写法在不存在时使用默认值
+//
+// 属于扩展语法 通过SetExtendedSyntax开启 默认模式下[a:b]是块名 ${a.b}原样保留
+// 继承和引用在全部文件合并之后处理 循环继承 循环引用和不存在的引用都会让加载失败
+// 引用在解密和读取@file:之前展开 ENC(...)和@file:的值只能作为整个值引用 不能拼接在其他值中
+
+// inherit 一个块继承的父块
+type inherit struct {
+	parent string
+	at     Origin
+}
+
+// resolveInherits 把父块的key复制到子块 父块先处理 支持多级继承
+func (s *snapshot) resolveInherits() error {
+	if len(s.inherits) == 0 {
+		return nil
+	}
+	children := make([]string, 0, len(s.inherits))
+	for child := range s.inherits {
+		children = append(children, child)
+	}
+	sort.Strings(children)
+	done := make(map[string]bool)
+	for _, child := range children {
+		if err := s.inheritFrom(child, done, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *snapshot) inheritFrom(sec string, done map[string]bool, chain []string) error {
+	in, has := s.inherits[sec]
+	if !has || done[sec] {
+		return nil
+	}
+	for i, name := range chain {
+		if name == sec {
+			return fmt.Errorf("config: %s: section inheritance cycle: %s", in.at, strings.Join(append(chain[i:], sec), " -> "))
+		}
+	}
+	if _, has := s.file[in.parent]; !has {
+		return fmt.Errorf("config: %s: [%s] inherits undefined section [%s]", in.at, sec, in.parent)
+	}
+	if err := s.inheritFrom(in.parent, done, append(chain, sec)); err != nil {
+		return err
+	}
+	for key, vals := range s.file[in.parent] {
+		origins := s.origins[in.parent][key]
+		if _, has := s.file[sec][key]; !has {
+			s.file[sec][key] = append([]string{}, vals...)
+			s.origins[sec][key] = append([]Origin{}, origins...)
+		} else if s.extends[sec][key] {
+			s.file[sec][key] = append(append([]string{}, vals...), s.file[sec][key]...)
+			s.origins[sec][key] = append(append([]Origin{}, origins...), s.origins[sec][key]...)
+		}
+	}
+	done[sec] = true
+	return nil
+}
+
+// errRefCycle 查找引用时遇到正在处理的key
+var errRefCycle = errors.New("reference cycle")
+
+// refResolver 展开值中的${sec.key}
+type refResolver struct {
+	snap *snapshot
+	// 1表示正在处理 2表示已经处理完
+	state map[string]int
+	// 正在处理的key 用于输出循环引用的路径
+	stack []string
+}
+
+// resolveRefs 展开全部值中的${sec.key} 名字中没有.的${VAR}是环境变量 保持原样
+func (s *snapshot) resolveRefs() error {
+	r := &refResolver{snap: s, state: make(map[string]int)}
+	secs := make([]string, 0, len(s.file))
+	for sec := range s.file {
+		secs = append(secs, sec)
+	}
+	sort.Strings(secs)
+	for _, sec := range secs {
+		keys := make([]string, 0, len(s.file[sec]))
+		for key := range s.file[sec] {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if err := r.resolve(sec, key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *refResolver) resolve(sec, key string) error {
+	id := sec + "." + key
+	switch r.state[id] {
+	case 1:
+		return errRefCycle
+	case 2:
+		return nil
+	}
+	r.state[id] = 1
+	r.stack = append(r.stack, id)
+	list, origins := r.snap.file[sec][key], r.snap.origins[sec][key]
+	out := make([]string, 0, len(list))
+	outorigins := make([]Origin, 0, len(list))
+	for i, val := range list {
+		var at Origin
+		if i < len(origins) {
+			at = origins[i]
+		}
+		vals, err := r.expand(val, at, sec, key)
+		if err != nil {
+			return err
+		}
+		for _, v := range vals {
+			out = append(out, v)
+			outorigins = append(outorigins, at)
+		}
+	}
+	r.snap.file[sec][key], r.snap.origins[sec][key] = out, outorigins
+	r.stack = r.stack[:len(r.stack)-1]
+	r.state[id] = 2
+	return nil
+}
+
+// lookup 返回引用的key展开后的值
+func (r *refResolver) lookup(name string) ([]string, bool, error) {
+	dot := strings.LastIndexByte(name, '.')
+	sec, key := name[:dot], name[dot+1:]
+	if _, has := r.snap.file[sec][key]; !has {
+		return nil, false, nil
+	}
+	if err := r.resolve(sec, key); err != nil {
+		return nil, true, err
+	}
+	return r.snap.file[sec][key], true, nil
+}
+
+// expand 展开一个值 整个值只是一个引用时 引用的数组全部展开为多个值
+func (r *refResolver) expand(val string, at Origin, sec, key string) ([]string, error) {
+	if !strings.Contains(val, "${") {
+		return []string{val}, nil
+	}
+	var b strings.Builder
+	s := val
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			b.WriteString(s)
+			return []string{b.String()}, nil
+		}
+		end := strings.IndexByte(s[i:], '}')
+		if i > 0 && s[i-1] == '$' || end < 0 {
+			// $${ 是字面的${ 留给环境变量展开处理
+			b.WriteString(s[:i+2])
+			s = s[i+2:]
+			continue
+		}
+		b.WriteString(s[:i])
+		inner := s[i+2 : i+end]
+		name, def, hasDef := inner, "", false
+		if j := strings.Index(inner, ":-"); j >= 0 {
+			name, def, hasDef = inner[:j], inner[j+2:], true
+		}
+		if !strings.ContainsRune(name, '.') {
+			// 环境变量
+			b.WriteString(s[i : i+end+1])
+			s = s[i+end+1:]
+			continue
+		}
+		vals, found, err := r.lookup(name)
+		if err == errRefCycle {
+			chain := append(append([]string{}, r.stack...), name)
+			for j, id := range chain {
+				if id == name {
+					chain = chain[j:]
+					break
+				}
+			}
+			return nil, fmt.Errorf("config: %s: [%s] %s: reference cycle: ${%s}", at, sec, key, strings.Join(chain, "} -> ${"))
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case !found && !hasDef:
+			return nil, fmt.Errorf("config: %s: [%s] %s: undefined reference ${%s}", at, sec, key, name)
+		case !found || len(vals) == 0:
+			vals = []string{def}
+		}
+		if len(s) == len(val) && i == 0 && end == len(s)-1 {
+			return append([]string{}, vals...), nil
+		}
+		if IsEncrypted(vals[0]) || IsFileRef(vals[0]) {
+			return nil, fmt.Errorf("config: %s: [%s] %s: ${%s} is an ENC() or @file: secret and can only be referenced as the whole value", at, sec, key, name)
+		}
+		b.WriteString(vals[0])
+		s = s[i+end+1:]
+	}
+}
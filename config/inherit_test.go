@@ -0,0 +1,120 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func loadExtended(t *testing.T, s string) (*Config, error) {
+	t.Helper()
+	c := New()
+	c.SetExtendedSyntax(true)
+	return c, c.LoadString(s)
+}
+
+func TestDefaultModeKeepsColonAndRefs(t *testing.T) {
+	// 以前的文件不受继承和引用影响
+	c := mustLoad(t, `[redis:6379]
+host = 127.0.0.1
+[app]
+msg = hello ${user.name}
+opts += b
+`)
+	if c.GetValue("redis:6379", "host") != "127.0.0.1" {
+		t.Errorf("sections %v", c.View().Sections())
+	}
+	if got := c.GetValue("app", "msg"); got != "hello ${user.name}" {
+		t.Errorf("msg = %q", got)
+	}
+	if got := c.GetValue("app", "opts +"); got != "b" {
+		t.Errorf("opts + = %q", got)
+	}
+}
+
+func TestInherit(t *testing.T) {
+	c, err := loadExtended(t, `[base]
+host = 10.0.0.1
+port = 3306
+opts = a
+[master : base]
+host = 10.0.0.2
+[slave : master]
+opts += b
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.GetValue("master", "host") != "10.0.0.2" || c.GetValue("master", "port") != "3306" {
+		t.Errorf("master = %v", c.GetSection("master"))
+	}
+	if c.GetValue("slave", "host") != "10.0.0.2" {
+		t.Errorf("multi-level: %v", c.GetSection("slave"))
+	}
+	if vals := c.GetValueSlice("slave", "opts"); strings.Join(vals, ",") != "a,b" {
+		t.Errorf("opts = %v", vals)
+	}
+	if o, _ := c.Origin("slave", "port"); o.Line != 3 {
+		t.Errorf("inherited origin %v", o)
+	}
+}
+
+func TestInheritErrors(t *testing.T) {
+	tests := []struct {
+		text, err string
+	}{
+		{"[a : nope]\nx = 1\n", "line 1: [a] inherits undefined section [nope]"},
+		{"[a : b]\nx = 1\n[b : a]\ny = 1\n", "inheritance cycle"},
+	}
+	for _, tt := range tests {
+		if _, err := loadExtended(t, tt.text); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("got %v, want %q", err, tt.err)
+		}
+	}
+}
+
+func TestRefs(t *testing.T) {
+	c, err := loadExtended(t, `[db]
+host = h1
+host = h2
+port = 3306
+[app]
+dsn = root@${db.host}:${db.port}
+hosts = ${db.host}
+mode = ${db.mode:-rw}
+home = ${HOME}
+lit = $${db.port}
+chain = ${app.dsn}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]string{
+		"dsn":   "root@h1:3306",
+		"mode":  "rw",
+		"home":  "${HOME}",
+		"lit":   "$${db.port}",
+		"chain": "root@h1:3306",
+	}
+	for key, want := range tests {
+		if got := c.GetValue("app", key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+	if vals := c.GetValueSlice("app", "hosts"); strings.Join(vals, ",") != "h1,h2" {
+		t.Errorf("whole-value ref: %v", vals)
+	}
+}
+
+func TestRefErrors(t *testing.T) {
+	tests := []struct {
+		text, err string
+	}{
+		{"[a]\nx = ${b.y}\n", "line 2: [a] x: undefined reference ${b.y}"},
+		{"[a]\nx = ${a.y}\ny = ${a.x}\n", "reference cycle: ${a.x} -> ${a.y} -> ${a.x}"},
+	}
+	for _, tt := range tests {
+		if _, err := loadExtended(t, tt.text); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("got %v, want %q", err, tt.err)
+		}
+	}
+}
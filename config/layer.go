@@ -0,0 +1,112 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// MergeMode 多层配置合并时数组key的处理方式
+type MergeMode int
+
+const (
+	// MergeReplace 上层的key替换下层同名key的全部值 默认方式
+	// 开启扩展语法时 上层写作 key += value 追加到下层的数组
+	MergeReplace MergeMode = iota
+	// MergeAppend 上层的值全部追加到下层的数组后面
+	MergeAppend
+)
+
+// SetMergeMode 设置多层配置和include合并时数组key的处理方式 对之后的Load生效
+func (c *Config) SetMergeMode(mode MergeMode) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.mode = mode
+}
+
+// Layer 一层配置文件
+type Layer struct {
+	Name string
+	// Optional 文件不存在时跳过 如local.ini
+	Optional bool
+}
+
+// LoadFiles 依次加载多个文件 后面的文件覆盖前面的文件
+// 如 LoadFiles("base.ini", "prod.ini")
+func (c *Config) LoadFiles(names ...string) error {
+	layers := make([]Layer, len(names))
+	for i, name := range names {
+		layers[i] = Layer{Name: name}
+	}
+	return c.LoadLayers(layers...)
+}
+
+// LoadLayers 依次加载多层配置 后面的层覆盖前面的层 合并规则见MergeMode
+// 全部成功后才替换当前内容
+func (c *Config) LoadLayers(layers ...Layer) error {
+	layers = append([]Layer{}, layers...)
+	return c.loadWith(func() (*snapshot, error) {
+		return loadLayers(layers, c.parseOptions())
+	}, true)
+}
+
+func loadLayers(layers []Layer, opts parseOptions) (*snapshot, error) {
+	result := newSnapshot()
+	for _, layer := range layers {
+		f, err := os.Open(layer.Name)
+		if err != nil {
+			if layer.Optional && os.IsNotExist(err) {
+				// 记录下来 之后创建文件时Watch可以发现
+				result.addFile(layer.Name)
+				continue
+			}
+			return nil, err
+		}
+		snap, err := parseFormat(f, filepath.Ext(layer.Name), layer.Name, opts)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		result.merge(snap, opts.mode)
+	}
+	return result, nil
+}
+
+// LoadFiles 默认配置依次加载多个文件
+func LoadFiles(names ...string) error {
+	return defConfig.LoadFiles(names...)
+}
+
+// merge 把上层upper合并到s
+func (s *snapshot) merge(upper *snapshot, mode MergeMode) {
+	for _, name := range upper.files {
+		s.addFile(name)
+	}
+	for pattern, names := range upper.globs {
+		s.globs[pattern] = names
+	}
+	s.warnings = append(s.warnings, upper.warnings...)
+	for child, in := range upper.inherits {
+		s.inherits[child] = in
+	}
+	for sec, keys := range upper.extends {
+		for key := range keys {
+			if s.extends[sec] == nil {
+				s.extends[sec] = make(map[string]bool)
+			}
+			s.extends[sec][key] = true
+		}
+	}
+	for sec, values := range upper.file {
+		s.addSection(sec, upper.sections[sec])
+		for key, vals := range values {
+			origins := upper.origins[sec][key]
+			if mode == MergeAppend || upper.appends[sec][key] {
+				s.file[sec][key] = append(append([]string{}, s.file[sec][key]...), vals...)
+				s.origins[sec][key] = append(append([]Origin{}, s.origins[sec][key]...), origins...)
+				continue
+			}
+			s.file[sec][key] = append([]string{}, vals...)
+			s.origins[sec][key] = append([]Origin{}, origins...)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFiles 在临时目录中创建文件 返回目录
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	for name, content := range files {
+		name = filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadLayers(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"base.ini": "[db]\nhost = a\nhost = b\nport = 3306\n",
+		"prod.ini": "[db]\nhost = c\n[cache]\nsize = 1\n",
+	})
+	c := New()
+	err := c.LoadLayers(
+		Layer{Name: filepath.Join(dir, "base.ini")},
+		Layer{Name: filepath.Join(dir, "prod.ini")},
+		Layer{Name: filepath.Join(dir, "local.ini"), Optional: true},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vals := c.GetValueSlice("db", "host"); strings.Join(vals, ",") != "c" {
+		t.Errorf("host = %v", vals)
+	}
+	if c.GetValue("db", "port") != "3306" || c.GetValue("cache", "size") != "1" {
+		t.Error("lower layer lost")
+	}
+	if o, _ := c.Origin("db", "host"); o.File != filepath.Join(dir, "prod.ini") || o.Line != 2 {
+		t.Errorf("origin %v", o)
+	}
+	if err := c.LoadLayers(Layer{Name: filepath.Join(dir, "none.ini")}); err == nil {
+		t.Error("missing required layer accepted")
+	}
+	if c.GetValue("db", "host") != "c" {
+		t.Error("failed load replaced the config")
+	}
+
+	c = New()
+	c.SetMergeMode(MergeAppend)
+	if err := c.LoadFiles(filepath.Join(dir, "base.ini"), filepath.Join(dir, "prod.ini")); err != nil {
+		t.Fatal(err)
+	}
+	if vals := c.GetValueSlice("db", "host"); strings.Join(vals, ",") != "a,b,c" {
+		t.Errorf("append mode host = %v", vals)
+	}
+}
+
+func TestAppendKey(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"base.ini": "[db]\nhost = a\n",
+		"prod.ini": "[db]\nhost += b\n",
+	})
+	names := []string{filepath.Join(dir, "base.ini"), filepath.Join(dir, "prod.ini")}
+
+	// 默认模式下 "host +" 是普通的key 和以前一样
+	c := New()
+	if err := c.LoadFiles(names...); err != nil {
+		t.Fatal(err)
+	}
+	if c.GetValue("db", "host") != "a" || c.GetValue("db", "host +") != "b" {
+		t.Errorf("default mode: %v", c.GetSection("db"))
+	}
+
+	c = New()
+	c.SetExtendedSyntax(true)
+	if err := c.LoadFiles(names...); err != nil {
+		t.Fatal(err)
+	}
+	if vals := c.GetValueSlice("db", "host"); strings.Join(vals, ",") != "a,b" {
+		t.Errorf("extended host = %v", vals)
+	}
+}
+
+func TestInclude(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.ini":       "include = common.ini\ninclude_glob = conf.d/*.ini\n[db]\nport = 3307\n",
+		"common.ini":     "[db]\nhost = common\nport = 3306\n",
+		"conf.d/a.ini":   "[cache]\nsize = 1\n",
+		"conf.d/b.ini":   "[cache]\nsize = 2\n",
+		"cycle/a.ini":    "include = b.ini\n",
+		"cycle/b.ini":    "include = a.ini\n",
+		"missing.ini":    "include = nope.ini\n",
+		"outside.ini":    "[db]\ninclude = common.ini\n",
+		"nested/top.ini": "include = ../common.ini\n",
+	})
+	c := New()
+	if err := c.LoadFile(filepath.Join(dir, "main.ini")); err != nil {
+		t.Fatal(err)
+	}
+	if c.GetValue("db", "host") != "common" || c.GetValue("db", "port") != "3307" {
+		t.Errorf("db = %v", c.GetSection("db"))
+	}
+	if c.GetValue("cache", "size") != "2" {
+		t.Errorf("glob order: size = %q", c.GetValue("cache", "size"))
+	}
+	o, _ := c.Origin("db", "host")
+	if o.File != filepath.Join(dir, "common.ini") || o.Include != filepath.Join(dir, "main.ini")+":1" {
+		t.Errorf("include origin %+v", o)
+	}
+	if o, _ := c.Origin("db", "port"); o.Include != "" {
+		t.Errorf("own key marked as included: %+v", o)
+	}
+
+	if err := c.LoadFile(filepath.Join(dir, "nested", "top.ini")); err != nil {
+		t.Fatal(err)
+	}
+	if c.GetValue("db", "host") != "common" {
+		t.Error("relative include not resolved from the including file")
+	}
+
+	err := New().LoadFile(filepath.Join(dir, "cycle", "a.ini"))
+	if err == nil || !strings.Contains(err.Error(), "include cycle") {
+		t.Errorf("cycle: %v", err)
+	}
+	if err := New().LoadFile(filepath.Join(dir, "missing.ini")); err == nil {
+		t.Error("missing include accepted")
+	}
+	// 块里面的include是普通的key
+	c = New()
+	if err := c.LoadFile(filepath.Join(dir, "outside.ini")); err != nil {
+		t.Fatal(err)
+	}
+	if c.GetValue("db", "include") != "common.ini" {
+		t.Errorf("include inside a section: %v", c.GetSection("db"))
+	}
+}
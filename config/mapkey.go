@@ -0,0 +1,137 @@
+package config
+
+import (
+	"sort"
+	"strings"
+)
+
+// 重复的key组成数组 带子key的key组成map
+//
+//	[lb]
+//	weights[a] = 3
+//	weights[b] = 7
+//	limits.api.read = 100
+//	limits.api.write = 10
+//
+//	config.Section("lb").Map("weights")    // {"a": "3", "b": "7"}
+//	config.Section("lb").Map("limits")     // {"api.read": "100", "api.write": "10"}
+//	config.Section("lb").Map("limits.api") // {"read": "100", "write": "10"}
+//
+// weights[a][b] weights[a].b 和 weights.a.b 是同一个子key
+// []中的内容保持原样 可以包含. 如hosts[a.example.com]
+// key按原样保存 GetValue("lb", "weights[a]")仍然可以读取
+
+// splitKey 把key按[]和.分成路径 格式不对时返回nil
+func splitKey(key string) []string {
+	var parts []string
+	rest := key
+	for rest != "" {
+		var part string
+		if rest[0] == '[' {
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil
+			}
+			part, rest = strings.TrimSpace(rest[1:end]), rest[end+1:]
+		} else {
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			part, rest = strings.TrimSpace(rest[:end]), rest[end:]
+			if strings.ContainsRune(part, ']') {
+				return nil
+			}
+		}
+		if part == "" {
+			return nil
+		}
+		parts = append(parts, part)
+		if strings.HasPrefix(rest, ".") {
+			rest = rest[1:]
+			if rest == "" || rest[0] == '[' {
+				return nil
+			}
+		}
+	}
+	return parts
+}
+
+// subKey 返回key在name下面的子key 如weights[a]在weights下面是a
+func subKey(key, name string) (string, bool) {
+	kp, np := splitKey(key), splitKey(name)
+	if len(np) == 0 || len(kp) <= len(np) {
+		return "", false
+	}
+	for i := range np {
+		if kp[i] != np[i] {
+			return "", false
+		}
+	}
+	return strings.Join(kp[len(np):], "."), true
+}
+
+// GetMapSlice 返回name下面全部子key的值 同一个子key有多种写法时按key的字母顺序合并
+func (m ConfigSection) GetMapSlice(name string) map[string][]string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	res := make(map[string][]string)
+	for _, key := range keys {
+		if sub, ok := subKey(key, name); ok {
+			res[sub] = append(res[sub], m[key]...)
+		}
+	}
+	return res
+}
+
+// GetMap 返回name下面每个子key的第一个值
+func (m ConfigSection) GetMap(name string) map[string]string {
+	res := make(map[string]string)
+	for sub, vals := range m.GetMapSlice(name) {
+		if len(vals) > 0 {
+			res[sub] = vals[0]
+		}
+	}
+	return res
+}
+
+// Map 返回name下面每个子key的第一个值 没有时返回空map
+func (s SectionView) Map(name string) map[string]string {
+	return s.m.GetMap(name)
+}
+
+// MapSlice 返回name下面每个子key的全部值
+func (s SectionView) MapSlice(name string) map[string][]string {
+	return s.m.GetMapSlice(name)
+}
+
+// GetMap 返回[sec]中name下面每个子key的第一个值
+func (v View) GetMap(sec, name string) map[string]string {
+	return v.Section(sec).Map(name)
+}
+
+// GetMapSlice 返回[sec]中name下面每个子key的全部值
+func (v View) GetMapSlice(sec, name string) map[string][]string {
+	return v.Section(sec).MapSlice(name)
+}
+
+// GetMap 返回[sec]中name下面每个子key的第一个值
+func (c *Config) GetMap(sec, name string) map[string]string {
+	return c.View().GetMap(sec, name)
+}
+
+// GetMapSlice 返回[sec]中name下面每个子key的全部值
+func (c *Config) GetMapSlice(sec, name string) map[string][]string {
+	return c.View().GetMapSlice(sec, name)
+}
+
+func GetMap(sec, name string) map[string]string {
+	return defConfig.GetMap(sec, name)
+}
+
+func GetMapSlice(sec, name string) map[string][]string {
+	return defConfig.GetMapSlice(sec, name)
+}
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want []string
+	}{
+		{"weights", []string{"weights"}},
+		{"weights[a]", []string{"weights", "a"}},
+		{"weights[a][b]", []string{"weights", "a", "b"}},
+		{"weights[a].b", []string{"weights", "a", "b"}},
+		{"limits.api.read", []string{"limits", "api", "read"}},
+		{"hosts[a.example.com]", []string{"hosts", "a.example.com"}},
+		{"hosts[ a ]", []string{"hosts", "a"}},
+		{"weights[a", nil},
+		{"weights]a", nil},
+		{"weights[]", nil},
+		{"weights.", nil},
+		{"weights.[a]", nil},
+		{"a..b", nil},
+	}
+	for _, tt := range tests {
+		if got := splitKey(tt.key); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitKey(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestGetMap(t *testing.T) {
+	c := mustLoad(t, `[lb]
+weights[a] = 3
+weights[b] = 7
+weights.b = 8
+weights = 1
+limits.api.read = 100
+limits.api.write = 10
+limits[web][read] = 5
+hosts[a.example.com] = 1
+`)
+	if m := c.GetMap("lb", "weights"); !reflect.DeepEqual(m, map[string]string{"a": "3", "b": "8"}) {
+		t.Errorf("weights = %v", m)
+	}
+	// weights.b排在weights[b]前面
+	if m := c.GetMapSlice("lb", "weights"); !reflect.DeepEqual(m["b"], []string{"8", "7"}) {
+		t.Errorf("weights slice = %v", m)
+	}
+	want := map[string]string{"api.read": "100", "api.write": "10", "web.read": "5"}
+	if m := c.GetMap("lb", "limits"); !reflect.DeepEqual(m, want) {
+		t.Errorf("limits = %v", m)
+	}
+	if m := c.View().Section("lb").Map("limits.api"); !reflect.DeepEqual(m, map[string]string{"read": "100", "write": "10"}) {
+		t.Errorf("limits.api = %v", m)
+	}
+	if m := c.GetMap("lb", "hosts"); m["a.example.com"] != "1" {
+		t.Errorf("hosts = %v", m)
+	}
+	if m := c.GetMap("lb", "none"); m == nil || len(m) != 0 {
+		t.Errorf("none = %#v", m)
+	}
+	// key按原样保存
+	if c.GetValue("lb", "weights[a]") != "3" || c.GetValue("lb", "weights") != "1" {
+		t.Error("raw keys changed")
+	}
+}
+
+func TestUnmarshalMap(t *testing.T) {
+	c := mustLoad(t, `[lb]
+weight[a] = 3
+weight.b = 7
+tags[a] = x
+tags[a] = y
+[lb.weight]
+b = 1
+c = 2
+`)
+	var lb struct {
+		Weights map[string]int      `ini:"weight"`
+		Tags    map[string][]string `ini:"tags"`
+		Limits  map[string]int      `ini:"limit"`
+	}
+	if err := c.Unmarshal("lb", &lb); err != nil {
+		t.Fatal(err)
+	}
+	// 两处都有的子key使用[lb]中的值
+	if !reflect.DeepEqual(lb.Weights, map[string]int{"a": 3, "b": 7, "c": 2}) {
+		t.Errorf("weights = %v", lb.Weights)
+	}
+	if !reflect.DeepEqual(lb.Tags, map[string][]string{"a": {"x", "y"}}) {
+		t.Errorf("tags = %v", lb.Tags)
+	}
+	if lb.Limits != nil {
+		t.Errorf("limits = %v", lb.Limits)
+	}
+}
+
+type testLB struct {
+	Weights map[string]int `ini:"weight"`
+	Limits  map[string]int `ini:"limit,required"`
+	Bad     map[int]string `ini:"bad"`
+}
+
+func TestUnmarshalMapErrors(t *testing.T) {
+	c := mustLoad(t, `[lb]
+weight[a] = x
+`)
+	var lb testLB
+	err := c.Unmarshal("lb", &lb)
+	var berr BindErrors
+	if !errors.As(err, &berr) {
+		t.Fatalf("got %T %v", err, err)
+	}
+	want := []string{
+		`line 2: [lb] weight[a] (testLB.Weights[a]): invalid value "x"`,
+		"[lb] limit (testLB.Limits): required section [lb.limit] or keys limit[...] are missing",
+		"[lb] bad (testLB.Bad): unsupported map type map[int]string",
+	}
+	if len(berr) != len(want) {
+		t.Fatalf("got %d errors:\n%v", len(berr), err)
+	}
+	for i, w := range want {
+		if !strings.Contains(berr[i].Error(), w) {
+			t.Errorf("error %d: got %q, want %q", i, berr[i].Error(), w)
+		}
+	}
+}
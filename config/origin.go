@@ -0,0 +1,218 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Origin 配置值的来源
+type Origin struct {
+	// 文件名 通过Load或LoadString加载时为空
+	File string
+	// 所在的行号 从1开始
+	Line int
+	// 来自环境变量覆盖时为变量名
+	Env string
+	// 来自命令行参数时为参数名 如--db.dsn
+	Flag string
+	// 通过include读取时为include所在的位置 多层include从内到外用 < 连接
+	Include string
+}
+
+// Layer 值来自哪一层 file remote env或flag
+func (o Origin) Layer() string {
+	if o.Flag != "" {
+		return "flag"
+	}
+	if o.Env != "" {
+		return "env"
+	}
+	if strings.Contains(o.File, "://") {
+		return "remote"
+	}
+	return "file"
+}
+
+func (o Origin) String() string {
+	if o.Flag != "" {
+		return "flag " + o.Flag
+	}
+	if o.Env != "" {
+		return "env " + o.Env
+	}
+	if o.File == "" {
+		return "line " + strconv.Itoa(o.Line)
+	}
+	at := o.File
+	// json等格式没有行号
+	if o.Line > 0 {
+		at += ":" + strconv.Itoa(o.Line)
+	}
+	if o.Include != "" {
+		at += " (included from " + o.Include + ")"
+	}
+	return at
+}
+
+// includedFrom 标记s中的内容是通过at处的include读取的
+func (s *snapshot) includedFrom(at Origin) {
+	mark := func(o *Origin) {
+		if o.Include == "" {
+			o.Include = at.String()
+		} else {
+			o.Include += " < " + at.String()
+		}
+	}
+	for _, values := range s.origins {
+		for _, list := range values {
+			for i := range list {
+				mark(&list[i])
+			}
+		}
+	}
+	for sec, o := range s.sections {
+		mark(&o)
+		s.sections[sec] = o
+	}
+}
+
+// snapshot 一次加载的完整结果 包括值和每个值的来源
+type snapshot struct {
+	file configFile
+	// 块名 -> key -> 每个值的来源 和file中的数组一一对应
+	origins map[string]map[string][]Origin
+	// 块名 -> 块第一次出现的位置
+	sections map[string]Origin
+	// 使用 key += value 写法的key 合并时追加到下层
+	appends map[string]map[string]bool
+	// 读取过的文件 包括include的文件 用于Watch
+	files []string
+	// include_glob的模式和当时匹配到的文件
+	globs map[string][]string
+	// 解析时忽略的行
+	warnings []Diagnostic
+	// 加密保存的key 输出时需要隐藏
+	secrets map[string]map[string]bool
+	// [child : parent] 子块 -> 父块
+	inherits map[string]inherit
+	// 使用 key += value 写法的key 继承时追加到父块的数组后面
+	extends map[string]map[string]bool
+	// 内容通过检查并生效后执行一次 如远程配置记录etag和更新缓存 不会复制到clone的结果
+	commit func()
+}
+
+func newSnapshot() *snapshot {
+	return &snapshot{
+		file:     make(configFile),
+		origins:  make(map[string]map[string][]Origin),
+		sections: make(map[string]Origin),
+		appends:  make(map[string]map[string]bool),
+		globs:    make(map[string][]string),
+		secrets:  make(map[string]map[string]bool),
+		inherits: make(map[string]inherit),
+		extends:  make(map[string]map[string]bool),
+	}
+}
+
+// clone 复制一份 修改复制的结果不影响s
+func (s *snapshot) clone() *snapshot {
+	n := newSnapshot()
+	for sec, values := range s.file {
+		n.file[sec] = make(ConfigSection, len(values))
+		n.origins[sec] = make(map[string][]Origin, len(values))
+		for key, vals := range values {
+			n.file[sec][key] = append([]string{}, vals...)
+			n.origins[sec][key] = append([]Origin{}, s.origins[sec][key]...)
+		}
+	}
+	for sec, o := range s.sections {
+		n.sections[sec] = o
+	}
+	for sec, keys := range s.appends {
+		for key := range keys {
+			n.markAppend(sec, key)
+		}
+	}
+	for sec, keys := range s.extends {
+		for key := range keys {
+			if n.extends[sec] == nil {
+				n.extends[sec] = make(map[string]bool)
+			}
+			n.extends[sec][key] = true
+		}
+	}
+	for sec, keys := range s.secrets {
+		n.secrets[sec] = make(map[string]bool, len(keys))
+		for key := range keys {
+			n.secrets[sec][key] = true
+		}
+	}
+	for child, in := range s.inherits {
+		n.inherits[child] = in
+	}
+	for pattern, names := range s.globs {
+		n.globs[pattern] = names
+	}
+	n.files = append([]string{}, s.files...)
+	n.warnings = append([]Diagnostic{}, s.warnings...)
+	return n
+}
+
+// addSection 添加一个块 已经存在时保持不变
+func (s *snapshot) addSection(sec string, o Origin) {
+	if _, has := s.file[sec]; has {
+		return
+	}
+	s.file[sec] = make(ConfigSection)
+	s.origins[sec] = make(map[string][]Origin)
+	s.sections[sec] = o
+}
+
+// add 追加一个值 重复的key组成数组
+func (s *snapshot) add(sec, key, val string, o Origin) {
+	s.addSection(sec, o)
+	s.file[sec][key] = append(s.file[sec][key], val)
+	s.origins[sec][key] = append(s.origins[sec][key], o)
+}
+
+// addFile 记录读取过的文件
+func (s *snapshot) addFile(name string) {
+	for _, f := range s.files {
+		if f == name {
+			return
+		}
+	}
+	s.files = append(s.files, name)
+}
+
+// markAppend 标记key合并时追加到下层
+func (s *snapshot) markAppend(sec, key string) {
+	if s.appends[sec] == nil {
+		s.appends[sec] = make(map[string]bool)
+	}
+	s.appends[sec][key] = true
+	if s.extends[sec] == nil {
+		s.extends[sec] = make(map[string]bool)
+	}
+	s.extends[sec][key] = true
+}
+
+// set 替换key的全部值
+func (s *snapshot) set(sec, key string, vals []string, o Origin) {
+	s.addSection(sec, o)
+	origins := make([]Origin, len(vals))
+	for i := range origins {
+		origins[i] = o
+	}
+	s.file[sec][key] = vals
+	s.origins[sec][key] = origins
+}
+
+// origin 返回key第一个值的来源 不存在时返回块的位置
+func (s *snapshot) origin(sec, key string) (Origin, bool) {
+	if list := s.origins[sec][key]; len(list) > 0 {
+		return list[0], true
+	}
+	o, ok := s.sections[sec]
+	return o, ok
+}
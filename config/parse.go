@@ -0,0 +1,252 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// parseOptions 影响解析结果的设置
+type parseOptions struct {
+	// 数组key的合并方式
+	mode MergeMode
+	// 扩展语法 见syntax.go
+	extended bool
+}
+
+// parser 解析ini内容 处理include时记录正在解析的文件 用于检测循环引用
+type parser struct {
+	// 正在解析的文件链 绝对路径
+	stack []string
+	parseOptions
+}
+
+// parse 解析ini格式的内容 name用于记录每个值的来源和解析相对路径的include
+func parse(rd io.Reader, name string, opts parseOptions) (*snapshot, error) {
+	p := &parser{parseOptions: opts}
+	if name != "" {
+		if abs, err := filepath.Abs(name); err == nil {
+			p.stack = append(p.stack, abs)
+		}
+	}
+	return p.parse(rd, name)
+}
+
+// lineReader 按行读取 记录行号
+type lineReader struct {
+	r      *bufio.Reader
+	lineno int
+	eof    bool
+	// keep为true时记录读到的原始行 Document用来原样写回
+	keep bool
+	raw  []string
+}
+
+// next 读取下一行 去掉行尾的换行 没有更多内容时ok为false
+func (lr *lineReader) next() (line string, ok bool, err error) {
+	if lr.eof {
+		return "", false, nil
+	}
+	line, err = lr.r.ReadString('\n')
+	if err != nil {
+		if err != io.EOF {
+			return "", false, err
+		}
+		lr.eof = true
+		if line == "" {
+			return "", false, nil
+		}
+	}
+	lr.lineno++
+	line = strings.TrimRight(line, "\r\n")
+	if lr.keep {
+		lr.raw = append(lr.raw, line)
+	}
+	return line, true, nil
+}
+
+func (p *parser) parse(rd io.Reader, name string) (*snapshot, error) {
+	lr := &lineReader{r: bufio.NewReader(rd)}
+	var sec string
+	snap := newSnapshot()
+	if name != "" {
+		snap.addFile(name)
+	}
+	// include进来的内容 作为当前文件下面的一层
+	var lower *snapshot
+	for {
+		line, ok, err := lr.next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		at := Origin{File: name, Line: lr.lineno}
+		if p.extended {
+			// 行尾的\表示下一行是同一行的继续
+			if line, err = joinContinuation(lr, line); err != nil {
+				return nil, err
+			}
+		}
+		line = strings.TrimSpace(line)
+		//空行或者注释跳过 注释支持;和#开头的行
+		if line == "" || line[0] == ';' || line[0] == '#' {
+			continue
+		}
+		if p.extended && line[0] == '[' {
+			line = stripInlineComment(line)
+		}
+		//判断配置块[name]
+		if line[0] == '[' && line[len(line)-1] == ']' {
+			var parent string
+			sec, parent = parseHeader(line[1:len(line)-1], p.extended)
+			if strings.TrimSpace(sec) == "" {
+				snap.warn(at, line, "empty section name")
+			}
+			snap.addSection(sec, at)
+			if parent != "" {
+				if in, has := snap.inherits[sec]; has && in.parent != parent {
+					snap.warn(at, line, "section already inherits ["+in.parent+"] at "+in.at.String())
+				}
+				snap.inherits[sec] = inherit{parent: parent, at: at}
+			}
+			continue
+		}
+		if line[0] == '[' {
+			snap.warn(at, line, "malformed section header, missing ']'")
+			if !strings.Contains(line, "=") {
+				continue
+			}
+		}
+		pair := strings.SplitN(line, "=", 2)
+		if len(pair) != 2 {
+			snap.warn(at, line, "missing '=' in key/value line")
+			continue
+		}
+		key, val := strings.TrimSpace(pair[0]), strings.TrimSpace(pair[1])
+		// 扩展语法下 引号中的值可以为空
+		explicit := false
+		if p.extended {
+			var verr error
+			if val, explicit, verr = parseValue(lr, val); verr != nil {
+				snap.warn(at, line, verr.Error())
+				continue
+			}
+		}
+		if sec == "" {
+			// 块外面只支持include指令
+			if key == "include" || key == "include_glob" {
+				sub, ierr := p.include(name, key == "include_glob", val, at)
+				if ierr != nil {
+					return nil, ierr
+				}
+				if lower == nil {
+					lower = sub
+				} else {
+					lower.merge(sub, p.mode)
+				}
+			} else {
+				snap.warn(at, line, "key outside of any section")
+			}
+			continue
+		}
+		// 扩展语法下 key += value 表示追加到下层的数组 而不是替换
+		// 默认模式下 a+ 仍然是普通的key 以前的文件解析结果不变
+		appendkey := p.extended && strings.HasSuffix(key, "+")
+		if appendkey {
+			key = strings.TrimSpace(key[:len(key)-1])
+		}
+		if key == "" {
+			snap.warn(at, line, "empty key")
+			continue
+		}
+		if val == "" && !explicit {
+			snap.warn(at, line, "empty value, key is ignored")
+			continue
+		}
+		snap.add(sec, key, val, at)
+		if appendkey {
+			snap.markAppend(sec, key)
+		}
+	}
+	if lower != nil {
+		lower.merge(snap, p.mode)
+		// 当前文件作为上层时 仍然需要自己的追加标记
+		lower.appends = snap.appends
+		return lower, nil
+	}
+	return snap, nil
+}
+
+// parseHeader 解析块名 扩展语法下[child : parent]表示child继承parent
+// 默认模式下:是块名的一部分 如[redis:6379]
+func parseHeader(name string, extended bool) (sec, parent string) {
+	if i := strings.IndexByte(name, ':'); i >= 0 && extended {
+		return strings.TrimSpace(name[:i]), strings.TrimSpace(name[i+1:])
+	}
+	return name, ""
+}
+
+// include 解析被包含的文件 相对路径相对于当前文件所在的目录
+func (p *parser) include(from string, glob bool, pattern string, at Origin) (*snapshot, error) {
+	if !filepath.IsAbs(pattern) && from != "" {
+		pattern = filepath.Join(filepath.Dir(from), pattern)
+	}
+	names := []string{pattern}
+	if glob {
+		var err error
+		if names, err = filepath.Glob(pattern); err != nil {
+			return nil, fmt.Errorf("config: %s: include_glob %q: %v", at, pattern, err)
+		}
+		sort.Strings(names)
+	}
+	var result *snapshot
+	for _, name := range names {
+		sub, err := p.parseFile(name, at)
+		if err != nil {
+			return nil, err
+		}
+		if result == nil {
+			result = sub
+		} else {
+			result.merge(sub, p.mode)
+		}
+	}
+	if result == nil {
+		result = newSnapshot()
+	}
+	result.includedFrom(at)
+	if glob {
+		result.globs[pattern] = names
+	}
+	return result, nil
+}
+
+func (p *parser) parseFile(name string, at Origin) (*snapshot, error) {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return nil, fmt.Errorf("config: %s: include %q: %v", at, name, err)
+	}
+	for i, visiting := range p.stack {
+		if visiting == abs {
+			chain := append(append([]string{}, p.stack[i:]...), abs)
+			return nil, fmt.Errorf("config: %s: include cycle: %s", at, strings.Join(chain, " -> "))
+		}
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("config: %s: include: %v", at, err)
+	}
+	defer f.Close()
+	if ext := filepath.Ext(name); lookupFormat(ext) != nil {
+		return parseFormat(f, ext, name, p.parseOptions)
+	}
+	p.stack = append(p.stack, abs)
+	defer func() { p.stack = p.stack[:len(p.stack)-1] }()
+	return p.parse(f, name)
+}
@@ -0,0 +1,261 @@
+package config
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// 从http服务器加载配置
+//
+//	remote, err := config.LoadRemote(config.RemoteOptions{
+//		URL:       "http://config.internal/app/prod.ini",
+//		CacheFile: "/var/cache/app/prod.ini",
+//		HMACKey:   []byte("shared secret"),
+//	})
+//	w := remote.Watch(30 * time.Second)
+//
+// 轮询时带上If-None-Match 服务器返回304时不重新解析
+// 启动时服务器不可用 使用CacheFile中最近一次成功获取的内容
+
+// DefaultSignatureHeader 默认的签名header 内容为body的HMAC-SHA256的十六进制
+const DefaultSignatureHeader = "X-Config-Signature"
+
+// ErrSignature 签名不存在或者不正确
+var ErrSignature = errors.New("config: remote signature mismatch")
+
+// formatSuffix 缓存文件的格式保存在加上这个后缀的文件中
+const formatSuffix = ".format"
+
+// errNotModified 服务器返回304 内容没有变化
+var errNotModified = errors.New("config: not modified")
+
+// RemoteOptions 远程配置的设置
+type RemoteOptions struct {
+	URL string
+	// Format 内容的格式 如".ini" ".json" 为空时按url的扩展名或者Content-Type判断
+	Format string
+	// CacheFile 最近一次成功获取的内容保存的位置 为空时不保存
+	// 内容的格式保存在CacheFile加上.format的文件中 使用缓存时按同样的格式解析
+	CacheFile string
+	// HMACKey 不为空时要求响应带有正确的签名
+	HMACKey []byte
+	// SignatureHeader 签名所在的header 默认为DefaultSignatureHeader
+	SignatureHeader string
+	// Header 请求时附加的header 如认证信息
+	Header http.Header
+	// Client 默认使用10秒超时的http.Client
+	Client *http.Client
+}
+
+// Remote 一个远程配置源
+type Remote struct {
+	c    *Config
+	opts RemoteOptions
+
+	lock sync.Mutex
+	etag string
+	// 最近一次成功获取的内容
+	body []byte
+	// 最近一次获取失败的原因
+	lasterr error
+	// 当前内容来自缓存文件
+	cached bool
+	// 第一次加载已经完成 之后失败时不再使用缓存文件
+	started bool
+}
+
+// LoadRemote 从opts.URL加载配置 成功后可以通过Reload或Remote.Watch更新
+func (c *Config) LoadRemote(opts RemoteOptions) (*Remote, error) {
+	if opts.SignatureHeader == "" {
+		opts.SignatureHeader = DefaultSignatureHeader
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if _, err := url.Parse(opts.URL); err != nil {
+		return nil, err
+	}
+	r := &Remote{c: c, opts: opts}
+	if err := c.loadWith(r.load, true); err != nil {
+		return nil, err
+	}
+	r.lock.Lock()
+	r.started = true
+	r.lock.Unlock()
+	return r, nil
+}
+
+// LoadRemote 默认配置从http服务器加载
+func LoadRemote(opts RemoteOptions) (*Remote, error) {
+	return defConfig.LoadRemote(opts)
+}
+
+// Watch 每隔interval请求一次 内容变化时替换 失败时保留之前的内容并通知OnError
+func (r *Remote) Watch(interval time.Duration) *Watcher {
+	return r.c.watch(interval, true)
+}
+
+// LastError 最近一次请求失败的原因 成功后为nil
+func (r *Remote) LastError() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.lasterr
+}
+
+// FromCache 当前内容是否来自缓存文件 服务器恢复后变为false
+func (r *Remote) FromCache() bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.cached
+}
+
+// load 作为Config的loader 内容没有变化时返回errNotModified
+func (r *Remote) load() (*snapshot, error) {
+	body, format, etag, err := r.fetch()
+	r.lock.Lock()
+	r.lasterr = err
+	started := r.started
+	r.lock.Unlock()
+	if err == errNotModified {
+		return nil, err
+	}
+	if err != nil {
+		if started || r.opts.CacheFile == "" {
+			return nil, err
+		}
+		// 启动时服务器不可用 使用缓存
+		data, cerr := ioutil.ReadFile(r.opts.CacheFile)
+		if cerr != nil {
+			return nil, fmt.Errorf("%v (cache: %v)", err, cerr)
+		}
+		// 格式可能来自Content-Type 使用获取时记录的格式
+		format := r.formatOf("")
+		if saved, ferr := ioutil.ReadFile(r.opts.CacheFile + formatSuffix); ferr == nil && len(bytes.TrimSpace(saved)) > 0 {
+			format = string(bytes.TrimSpace(saved))
+		}
+		snap, err := parseFormat(bytes.NewReader(data), format, r.opts.URL, r.c.parseOptions())
+		if err != nil {
+			return nil, err
+		}
+		snap.commit = func() {
+			r.lock.Lock()
+			r.cached = true
+			r.lock.Unlock()
+		}
+		snap.files = nil
+		return snap, nil
+	}
+	snap, err := parseFormat(bytes.NewReader(body), format, r.opts.URL, r.c.parseOptions())
+	if err != nil {
+		return nil, err
+	}
+	// 内容通过全部检查并生效后才记录etag和更新缓存
+	// 解析或者检查失败时缓存保留最近一次可用的内容 下次请求仍然带着之前的etag 会重新获取并再次报告错误
+	snap.commit = func() {
+		r.lock.Lock()
+		r.etag, r.body, r.cached = etag, body, false
+		r.lock.Unlock()
+		if r.opts.CacheFile != "" {
+			err := writeCache(r.opts.CacheFile+formatSuffix, []byte(format))
+			if err == nil {
+				err = writeCache(r.opts.CacheFile, body)
+			}
+			if err != nil {
+				r.c.reportError(fmt.Errorf("config: remote cache: %v", err))
+			}
+		}
+	}
+	// url不是本地文件 不需要Watcher检查
+	snap.files = nil
+	return snap, nil
+}
+
+// fetch 请求一次 返回内容 格式和etag 304时返回errNotModified
+func (r *Remote) fetch() ([]byte, string, string, error) {
+	req, err := http.NewRequest("GET", r.opts.URL, nil)
+	if err != nil {
+		return nil, "", "", err
+	}
+	for key, vals := range r.opts.Header {
+		req.Header[key] = vals
+	}
+	r.lock.Lock()
+	etag, hasBody := r.etag, r.body != nil
+	r.lock.Unlock()
+	if etag != "" && hasBody {
+		req.Header.Set("If-None-Match", etag)
+	}
+	resp, err := r.opts.Client.Do(req)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("config: remote %s: %v", r.opts.URL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified && hasBody {
+		return nil, "", "", errNotModified
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", "", fmt.Errorf("config: remote %s: %s", r.opts.URL, resp.Status)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("config: remote %s: %v", r.opts.URL, err)
+	}
+	if len(r.opts.HMACKey) > 0 {
+		sig, err := hex.DecodeString(resp.Header.Get(r.opts.SignatureHeader))
+		mac := hmac.New(sha256.New, r.opts.HMACKey)
+		mac.Write(body)
+		if err != nil || !hmac.Equal(sig, mac.Sum(nil)) {
+			return nil, "", "", ErrSignature
+		}
+	}
+	return body, r.formatOf(resp.Header.Get("Content-Type")), resp.Header.Get("ETag"), nil
+}
+
+// formatOf 内容的格式 依次使用设置 url的扩展名和Content-Type
+func (r *Remote) formatOf(contentType string) string {
+	if r.opts.Format != "" {
+		return r.opts.Format
+	}
+	if u, err := url.Parse(r.opts.URL); err == nil {
+		if ext := path.Ext(u.Path); lookupFormat(ext) != nil {
+			return ext
+		}
+	}
+	if mt, _, err := mime.ParseMediaType(contentType); err == nil && mt == "application/json" {
+		return ".json"
+	}
+	return ".ini"
+}
+
+// writeCache 先写临时文件再改名 避免留下不完整的缓存
+func writeCache(name string, data []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(name), "."+filepath.Base(name)+".tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, name)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
@@ -0,0 +1,231 @@
+package config
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// configServer 返回body的测试服务器 记录304的次数
+type configServer struct {
+	sync.Mutex
+	body   string
+	etag   string
+	ctype  string
+	sigkey []byte
+	down   bool
+	notmod int
+}
+
+func (s *configServer) set(body, etag string) {
+	s.Lock()
+	defer s.Unlock()
+	s.body, s.etag = body, etag
+}
+
+func (s *configServer) setDown(down bool) {
+	s.Lock()
+	defer s.Unlock()
+	s.down = down
+}
+
+func (s *configServer) setKey(key []byte) {
+	s.Lock()
+	defer s.Unlock()
+	s.sigkey = key
+}
+
+func (s *configServer) notModified() int {
+	s.Lock()
+	defer s.Unlock()
+	return s.notmod
+}
+
+func (s *configServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+	if s.down {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+		return
+	}
+	if s.etag != "" && r.Header.Get("If-None-Match") == s.etag {
+		s.notmod++
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if s.sigkey != nil {
+		mac := hmac.New(sha256.New, s.sigkey)
+		mac.Write([]byte(s.body))
+		w.Header().Set(DefaultSignatureHeader, hex.EncodeToString(mac.Sum(nil)))
+	}
+	if s.ctype != "" {
+		w.Header().Set("Content-Type", s.ctype)
+	}
+	w.Header().Set("ETag", s.etag)
+	w.Write([]byte(s.body))
+}
+
+func newConfigServer(t *testing.T, body, etag string) (*configServer, string) {
+	s := &configServer{body: body, etag: etag}
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	return s, ts.URL + "/app.ini"
+}
+
+func TestRemoteNotModified(t *testing.T) {
+	s, url := newConfigServer(t, "[db]\nhost = a\n", `"v1"`)
+	c := New()
+	r, err := c.LoadRemote(RemoteOptions{URL: url})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.GetValue("db", "host") != "a" {
+		t.Fatalf("db = %v", c.GetSection("db"))
+	}
+	if o, _ := c.Origin("db", "host"); o.File != url || o.Layer() != "remote" {
+		t.Errorf("origin %+v", o)
+	}
+	if err := c.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if n := s.notModified(); n != 1 {
+		t.Errorf("304 responses: %d", n)
+	}
+	s.set("[db]\nhost = b\n", `"v2"`)
+	if err := c.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if c.GetValue("db", "host") != "b" || r.LastError() != nil {
+		t.Errorf("db = %v, %v", c.GetSection("db"), r.LastError())
+	}
+}
+
+func TestRemoteSignature(t *testing.T) {
+	s, url := newConfigServer(t, "[db]\nhost = a\n", "")
+	s.setKey([]byte("shared"))
+	c := New()
+	if _, err := c.LoadRemote(RemoteOptions{URL: url, HMACKey: []byte("shared")}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := New().LoadRemote(RemoteOptions{URL: url, HMACKey: []byte("other")})
+	if !errors.Is(err, ErrSignature) {
+		t.Errorf("wrong key: %v", err)
+	}
+	s.setKey(nil)
+	if _, err := New().LoadRemote(RemoteOptions{URL: url, HMACKey: []byte("shared")}); !errors.Is(err, ErrSignature) {
+		t.Errorf("missing signature: %v", err)
+	}
+}
+
+func TestRemoteCacheFallback(t *testing.T) {
+	dir := writeFiles(t, nil)
+	cache := filepath.Join(dir, "app.ini")
+	s, url := newConfigServer(t, "[db]\nhost = a\n", `"v1"`)
+	opts := RemoteOptions{URL: url, CacheFile: cache}
+	if _, err := New().LoadRemote(opts); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadFile(cache); string(data) != "[db]\nhost = a\n" {
+		t.Fatalf("cache = %q", data)
+	}
+
+	s.setDown(true)
+	c := New()
+	r, err := c.LoadRemote(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.GetValue("db", "host") != "a" || !r.FromCache() || r.LastError() == nil {
+		t.Errorf("fallback: %v cached=%v err=%v", c.GetSection("db"), r.FromCache(), r.LastError())
+	}
+	// 启动之后失败不再使用缓存 保留当前内容
+	if err := c.Reload(); err == nil {
+		t.Error("reload from a down server succeeded")
+	}
+	s.setDown(false)
+	if err := c.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if r.FromCache() {
+		t.Error("still from cache after the server recovered")
+	}
+
+	s.setDown(true)
+	if _, err := New().LoadRemote(RemoteOptions{URL: url, CacheFile: filepath.Join(dir, "none.ini")}); err == nil {
+		t.Error("missing cache accepted")
+	}
+}
+
+func TestRemoteCacheFormat(t *testing.T) {
+	dir := writeFiles(t, nil)
+	s := &configServer{body: `{"db": {"host": "a"}}`, etag: `"v1"`, ctype: "application/json"}
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+	// url没有扩展名 格式来自Content-Type
+	opts := RemoteOptions{URL: ts.URL + "/app", CacheFile: filepath.Join(dir, "app.cache")}
+	c := New()
+	if _, err := c.LoadRemote(opts); err != nil {
+		t.Fatal(err)
+	}
+	if c.GetValue("db", "host") != "a" {
+		t.Fatalf("db = %v", c.GetSection("db"))
+	}
+
+	s.setDown(true)
+	c = New()
+	r, err := c.LoadRemote(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.GetValue("db", "host") != "a" || !r.FromCache() || len(c.Warnings()) != 0 {
+		t.Errorf("cache parsed as ini: %v, warnings %v", c.GetSection("db"), c.Warnings())
+	}
+}
+
+func TestRemoteRejectedPayload(t *testing.T) {
+	dir := writeFiles(t, nil)
+	cache := filepath.Join(dir, "app.ini")
+	s, url := newConfigServer(t, "[db]\nport = 3306\n", `"v1"`)
+	c := New()
+	c.SetSchema(&Schema{Sections: []SectionSchema{{
+		Name: "db",
+		Keys: []KeySchema{{Name: "port", Type: TypeInt}},
+	}}})
+	var reported []error
+	c.OnError(func(err error) { reported = append(reported, err) })
+	if _, err := c.LoadRemote(RemoteOptions{URL: url, CacheFile: cache}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 可以解析 但是不符合声明
+	s.set("[db]\nport = x\n", `"v2"`)
+	for i := 0; i < 2; i++ {
+		if err := c.Reload(); !errors.As(err, new(ValidationErrors)) {
+			t.Fatalf("reload %d: %v", i, err)
+		}
+	}
+	if len(reported) != 2 || s.notModified() != 0 {
+		t.Errorf("reported %d errors, %d not modified", len(reported), s.notModified())
+	}
+	if c.GetValue("db", "port") != "3306" {
+		t.Errorf("rejected payload applied: %v", c.GetSection("db"))
+	}
+	if data, _ := ioutil.ReadFile(cache); string(data) != "[db]\nport = 3306\n" {
+		t.Errorf("cache overwritten: %q", data)
+	}
+
+	s.set("[db]\nport = 3307\n", `"v3"`)
+	if err := c.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadFile(cache); string(data) != "[db]\nport = 3307\n" {
+		t.Errorf("cache not updated: %q", data)
+	}
+}
@@ -0,0 +1,523 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 配置块的声明和检查
+//
+//	schema := &config.Schema{Sections: []config.SectionSchema{{
+//		Name:     "db",
+//		Required: true,
+//		Keys: []config.KeySchema{
+//			{Name: "dsn", Required: true, Pattern: `^\w+:.*@`},
+//			{Name: "maxopenconns", Type: config.TypeInt, Min: "1", Max: "1000"},
+//			{Name: "timeout", Type: config.TypeDuration, Max: "30s"},
+//			{Name: "mode", Enum: []string{"rw", "ro"}},
+//			{Name: "slave", MinLen: 1, MaxLen: 8},
+//			{Name: "maxconn", Deprecated: "use maxopenconns"},
+//		},
+//	}}}
+//	warnings, err := config.Validate(schema)
+//
+// err为ValidationErrors 包含全部违反声明的值 warnings为未知的key和已废弃的key
+
+// ValueType 值的类型 和Value的转换方法对应
+type ValueType int
+
+const (
+	TypeString ValueType = iota
+	TypeInt
+	TypeFloat
+	TypeBool
+	TypeDuration
+	TypeSize
+	TypeTime
+	TypeURL
+)
+
+func (t ValueType) String() string {
+	switch t {
+	case TypeString:
+		return "string"
+	case TypeInt:
+		return "int"
+	case TypeFloat:
+		return "float"
+	case TypeBool:
+		return "bool"
+	case TypeDuration:
+		return "duration"
+	case TypeSize:
+		return "size"
+	case TypeTime:
+		return "time"
+	case TypeURL:
+		return "url"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseValueType 按名字解析类型 如"int" "duration"
+func ParseValueType(s string) (ValueType, error) {
+	for t := TypeString; t <= TypeURL; t++ {
+		if t.String() == s {
+			return t, nil
+		}
+	}
+	return TypeString, fmt.Errorf("unknown value type %q", s)
+}
+
+// MarshalText 使用类型的名字 方便把声明写在json文件中
+func (t ValueType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText 从类型的名字解析 空字符串为string
+func (t *ValueType) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*t = TypeString
+		return nil
+	}
+	v, err := ParseValueType(string(text))
+	if err != nil {
+		return err
+	}
+	*t = v
+	return nil
+}
+
+// KeySchema 一个key的声明
+type KeySchema struct {
+	Name     string
+	Type     ValueType
+	Required bool
+	// 允许的值
+	Enum []string
+	// 数值的范围 按Type解析 如"1" "3s" "4MB" 为空表示不限制
+	// string类型按字符串长度检查
+	Min, Max string
+	// 每个值需要匹配的正则表达式
+	Pattern string
+	// 值的个数 MaxLen为0表示不限制
+	MinLen, MaxLen int
+	// 已经废弃 内容为替代的提示 如"use maxopenconns"
+	Deprecated string
+	// 说明 用于命令行参数的帮助信息
+	Help string
+}
+
+// SectionSchema 一个块的声明
+type SectionSchema struct {
+	// 块名 支持path.Match的通配符 如"servers.*"
+	Name     string
+	Required bool
+	Keys     []KeySchema
+	// 允许没有声明的key 不产生警告
+	AllowUnknown bool
+}
+
+// Schema 配置的声明 没有声明的块不检查 第一次使用后不要再修改
+type Schema struct {
+	Sections []SectionSchema
+
+	once sync.Once
+	err  error
+	keys map[string]map[string]*compiledKey
+}
+
+type compiledKey struct {
+	*KeySchema
+	pattern  *regexp.Regexp
+	min, max *float64
+}
+
+// Violation 一个不符合声明的值
+type Violation struct {
+	// 值所在的位置 key不存在时为块所在的位置
+	Origin  Origin
+	Section string
+	Key     string
+	Reason  string
+}
+
+func (v Violation) String() string {
+	msg := "[" + v.Section + "]"
+	if v.Key != "" {
+		msg += " " + v.Key
+	}
+	msg += ": " + v.Reason
+	if v.Origin.File != "" || v.Origin.Line > 0 || v.Origin.Env != "" || v.Origin.Flag != "" {
+		msg = v.Origin.String() + ": " + msg
+	}
+	return msg
+}
+
+// ValidationErrors Validate发现的全部错误
+type ValidationErrors []Violation
+
+func (e ValidationErrors) Error() string {
+	lines := make([]string, len(e))
+	for i, v := range e {
+		lines[i] = v.String()
+	}
+	return fmt.Sprintf("config: %d validation error(s):\n\t%s", len(e), strings.Join(lines, "\n\t"))
+}
+
+// compile 检查声明本身 解析正则和范围 同一个块或者同一个key不能重复声明
+func (s *Schema) compile() error {
+	s.once.Do(func() {
+		s.keys = make(map[string]map[string]*compiledKey)
+		for i := range s.Sections {
+			sec := &s.Sections[i]
+			if _, err := path.Match(sec.Name, ""); err != nil {
+				s.err = fmt.Errorf("config: schema [%s]: %v", sec.Name, err)
+				return
+			}
+			if _, dup := s.keys[sec.Name]; dup {
+				s.err = fmt.Errorf("config: schema [%s]: duplicate section", sec.Name)
+				return
+			}
+			keys := make(map[string]*compiledKey)
+			for j := range sec.Keys {
+				ks := &sec.Keys[j]
+				if _, dup := keys[ks.Name]; dup {
+					s.err = fmt.Errorf("config: schema [%s] %s: duplicate key", sec.Name, ks.Name)
+					return
+				}
+				ck := &compiledKey{KeySchema: ks}
+				var err error
+				if ks.Pattern != "" {
+					if ck.pattern, err = regexp.Compile(ks.Pattern); err != nil {
+						s.err = fmt.Errorf("config: schema [%s] %s: pattern: %v", sec.Name, ks.Name, err)
+						return
+					}
+				}
+				if ck.min, err = ks.bound(ks.Min); err != nil {
+					s.err = fmt.Errorf("config: schema [%s] %s: min: %v", sec.Name, ks.Name, err)
+					return
+				}
+				if ck.max, err = ks.bound(ks.Max); err != nil {
+					s.err = fmt.Errorf("config: schema [%s] %s: max: %v", sec.Name, ks.Name, err)
+					return
+				}
+				keys[ks.Name] = ck
+			}
+			s.keys[sec.Name] = keys
+		}
+	})
+	return s.err
+}
+
+func (k *KeySchema) bound(s string) (*float64, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if k.Type == TypeString {
+		n, err := strconv.Atoi(s)
+		f := float64(n)
+		return &f, err
+	}
+	f, err := k.number(s)
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// number 按类型解析值 用于范围检查
+func (k *KeySchema) number(s string) (float64, error) {
+	switch k.Type {
+	case TypeInt:
+		// 和IntE一样只接受十进制
+		n, err := strconv.ParseInt(s, 10, 64)
+		return float64(n), numError(err)
+	case TypeFloat:
+		f, err := strconv.ParseFloat(s, 64)
+		return f, numError(err)
+	case TypeDuration:
+		d, err := time.ParseDuration(s)
+		return float64(d), err
+	case TypeSize:
+		n, err := ParseSize(s)
+		return float64(n), err
+	case TypeTime:
+		t, err := ParseTime(s)
+		return float64(t.UnixNano()), err
+	}
+	return 0, fmt.Errorf("range is not supported for %s", k.Type)
+}
+
+// check 检查一个值 返回不符合的原因
+// secret为true时原因中不包含值 值显示为Mask 解析错误的详情中也可能带有值 一起省略
+func (k *compiledKey) check(val string, secret bool) string {
+	shown := strconv.Quote(val)
+	if secret {
+		shown = Mask
+	}
+	detail := func(err error) string {
+		if secret {
+			return ""
+		}
+		return ": " + err.Error()
+	}
+	var n float64
+	switch k.Type {
+	case TypeString:
+		n = float64(len(val))
+	case TypeBool:
+		if _, err := ParseBool(val); err != nil {
+			return fmt.Sprintf("invalid bool %s", shown)
+		}
+	case TypeURL:
+		if _, err := parseURL(val); err != nil {
+			return fmt.Sprintf("invalid url %s%s", shown, detail(err))
+		}
+	default:
+		var err error
+		if n, err = k.number(val); err != nil {
+			return fmt.Sprintf("invalid %s %s%s", k.Type, shown, detail(err))
+		}
+	}
+	if len(k.Enum) > 0 {
+		found := false
+		for _, e := range k.Enum {
+			if e == val {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Sprintf("%s is not one of %s", shown, strings.Join(k.Enum, ", "))
+		}
+	}
+	if k.pattern != nil && !k.pattern.MatchString(val) {
+		return fmt.Sprintf("%s does not match %s", shown, k.Pattern)
+	}
+	what := "value"
+	if k.Type == TypeString {
+		what = "length"
+	}
+	if k.min != nil && n < *k.min {
+		return fmt.Sprintf("%s of %s is less than %s", what, shown, k.Min)
+	}
+	if k.max != nil && n > *k.max {
+		return fmt.Sprintf("%s of %s is greater than %s", what, shown, k.Max)
+	}
+	return ""
+}
+
+// validate 检查snap 返回错误和警告 都按位置排序
+func (s *Schema) validate(snap *snapshot) (errs, warnings []Violation, err error) {
+	if err := s.compile(); err != nil {
+		return nil, nil, err
+	}
+	for i := range s.Sections {
+		sec := &s.Sections[i]
+		keys := s.keys[sec.Name]
+		matched := false
+		for name, values := range snap.file {
+			if ok, _ := path.Match(sec.Name, name); !ok {
+				continue
+			}
+			matched = true
+			at := snap.sections[name]
+			for _, ks := range sec.Keys {
+				ck := keys[ks.Name]
+				vals, has := values[ks.Name]
+				if !has {
+					if ks.Required {
+						errs = append(errs, Violation{Origin: at, Section: name, Key: ks.Name, Reason: ErrRequired.Error()})
+					}
+					continue
+				}
+				origins := snap.origins[name][ks.Name]
+				where := at
+				if len(origins) > 0 {
+					where = origins[0]
+				}
+				if ks.Deprecated != "" {
+					warnings = append(warnings, Violation{Origin: where, Section: name, Key: ks.Name, Reason: "deprecated, " + ks.Deprecated})
+				}
+				if len(vals) < ks.MinLen || ks.MaxLen > 0 && len(vals) > ks.MaxLen {
+					errs = append(errs, Violation{Origin: where, Section: name, Key: ks.Name,
+						Reason: fmt.Sprintf("has %d value(s), want %s", len(vals), lenRange(ks.MinLen, ks.MaxLen))})
+				}
+				secret := snap.secrets[name][ks.Name]
+				for j, val := range vals {
+					if reason := ck.check(val, secret); reason != "" {
+						if j < len(origins) {
+							where = origins[j]
+						}
+						errs = append(errs, Violation{Origin: where, Section: name, Key: ks.Name, Reason: reason})
+					}
+				}
+			}
+			if sec.AllowUnknown {
+				continue
+			}
+			for key := range values {
+				if _, known := keys[key]; known || isMapKey(key, keys) {
+					continue
+				}
+				reason := "unknown key"
+				if hint := closestKey(key, sec.Keys); hint != "" {
+					reason += ", did you mean " + hint + "?"
+				}
+				where := at
+				if origins := snap.origins[name][key]; len(origins) > 0 {
+					where = origins[0]
+				}
+				warnings = append(warnings, Violation{Origin: where, Section: name, Key: key, Reason: reason})
+			}
+		}
+		if !matched && sec.Required {
+			errs = append(errs, Violation{Section: sec.Name, Reason: "required section is missing"})
+		}
+	}
+	sortViolations(errs)
+	sortViolations(warnings)
+	return errs, warnings, nil
+}
+
+// isMapKey key是否是已声明key的子key 如声明了weights时的weights[a]
+func isMapKey(key string, keys map[string]*compiledKey) bool {
+	for name := range keys {
+		if _, ok := subKey(key, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func lenRange(min, max int) string {
+	switch {
+	case max == 0:
+		return "at least " + strconv.Itoa(min)
+	case min == max:
+		return strconv.Itoa(min)
+	default:
+		return strconv.Itoa(min) + " to " + strconv.Itoa(max)
+	}
+}
+
+func sortViolations(list []Violation) {
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i].Origin, list[j].Origin
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		if list[i].Section != list[j].Section {
+			return list[i].Section < list[j].Section
+		}
+		return list[i].Key < list[j].Key
+	})
+}
+
+// closestKey 返回编辑距离最近的已声明key 用于提示拼写错误
+func closestKey(key string, keys []KeySchema) string {
+	best, bestdist := "", 3
+	for _, ks := range keys {
+		if d := editDistance(strings.ToLower(key), strings.ToLower(ks.Name)); d < bestdist {
+			best, bestdist = ks.Name, d
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// Validate 按schema检查当前生效的内容
+// 返回的warnings包括未知的key和已废弃的key 不符合声明的值作为ValidationErrors返回
+func (c *Config) Validate(schema *Schema) ([]Violation, error) {
+	snap := c.snapshot()
+	if snap == nil {
+		snap = newSnapshot()
+	}
+	errs, warnings, err := schema.validate(snap)
+	if err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		return warnings, ValidationErrors(errs)
+	}
+	return warnings, nil
+}
+
+// SetSchema 设置加载时使用的声明 对之后的Load和Reload生效
+// 不符合声明时加载失败并保留之前的内容 警告可以通过Warnings获取
+func (c *Config) SetSchema(schema *Schema) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.schema = schema
+}
+
+// validateOnLoad 加载时按SetSchema设置的声明检查
+func (c *Config) validateOnLoad(snap *snapshot) error {
+	c.lock.Lock()
+	schema := c.schema
+	c.lock.Unlock()
+	if schema == nil {
+		return nil
+	}
+	errs, warnings, err := schema.validate(snap)
+	if err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return ValidationErrors(errs)
+	}
+	for _, w := range warnings {
+		snap.warn(w.Origin, "["+w.Section+"] "+w.Key, w.Reason)
+	}
+	return nil
+}
+
+// Validate 按schema检查默认配置
+func Validate(schema *Schema) ([]Violation, error) {
+	return defConfig.Validate(schema)
+}
+
+// SetSchema 设置默认配置加载时使用的声明
+func SetSchema(schema *Schema) {
+	defConfig.SetSchema(schema)
+}
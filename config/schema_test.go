@@ -0,0 +1,180 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testSchema() *Schema {
+	return &Schema{Sections: []SectionSchema{{
+		Name:     "db",
+		Required: true,
+		Keys: []KeySchema{
+			{Name: "dsn", Required: true, Pattern: `^\w+@`},
+			{Name: "maxconn", Type: TypeInt, Min: "1", Max: "1000"},
+			{Name: "timeout", Type: TypeDuration, Max: "30s"},
+			{Name: "mode", Enum: []string{"rw", "ro"}},
+			{Name: "slave", MinLen: 1, MaxLen: 2, Min: "2"},
+			{Name: "oldconn", Deprecated: "use maxconn"},
+			{Name: "weights", Type: TypeInt},
+		},
+	}, {
+		Name:         "servers.*",
+		AllowUnknown: true,
+		Keys:         []KeySchema{{Name: "addr", Required: true, Type: TypeURL}},
+	}}}
+}
+
+func TestValidate(t *testing.T) {
+	c := mustLoad(t, `[db]
+dsn = root@tcp
+maxconn = 100
+timeout = 3s
+mode = rw
+slave = s1
+oldconn = 1
+maxcon = 2
+weights[a] = 1
+[servers.a]
+addr = http://a
+other = 1
+`)
+	warnings, err := c.Validate(testSchema())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"line 7: [db] oldconn: deprecated, use maxconn",
+		"line 8: [db] maxcon: unknown key, did you mean maxconn?",
+	}
+	if len(warnings) != len(want) {
+		t.Fatalf("warnings: %v", warnings)
+	}
+	for i, w := range want {
+		if warnings[i].String() != w {
+			t.Errorf("warning %d: got %q, want %q", i, warnings[i], w)
+		}
+	}
+}
+
+func TestValidateErrors(t *testing.T) {
+	c := mustLoad(t, `[db]
+dsn = tcp
+maxconn = 0x10
+timeout = 1m
+mode = wo
+slave = a
+slave = bb
+slave = cc
+[servers.b]
+addr = ://
+`)
+	_, err := c.Validate(testSchema())
+	var verr ValidationErrors
+	if !errors.As(err, &verr) {
+		t.Fatalf("got %v", err)
+	}
+	want := []string{
+		`line 2: [db] dsn: "tcp" does not match`,
+		`line 3: [db] maxconn: invalid int "0x10"`,
+		`line 4: [db] timeout: value of "1m" is greater than 30s`,
+		`line 5: [db] mode: "wo" is not one of rw, ro`,
+		`line 6: [db] slave: has 3 value(s), want 1 to 2`,
+		`line 6: [db] slave: length of "a" is less than 2`,
+		`line 10: [servers.b] addr: invalid url`,
+	}
+	if len(verr) != len(want) {
+		t.Fatalf("got %d errors:\n%v", len(verr), err)
+	}
+	for i, w := range want {
+		if !strings.Contains(verr[i].String(), w) {
+			t.Errorf("error %d: got %q, want %q", i, verr[i], w)
+		}
+	}
+
+	_, err = New().Validate(testSchema())
+	if !errors.As(err, &verr) || len(verr) != 1 || verr[0].Reason != "required section is missing" {
+		t.Errorf("empty config: %v", err)
+	}
+}
+
+func TestSchemaCompileErrors(t *testing.T) {
+	tests := []struct {
+		schema *Schema
+		err    string
+	}{
+		{&Schema{Sections: []SectionSchema{{Name: "["}}}, "schema [[]"},
+		{&Schema{Sections: []SectionSchema{{Name: "a"}, {Name: "a"}}}, "[a]: duplicate section"},
+		{&Schema{Sections: []SectionSchema{{Name: "a", Keys: []KeySchema{{Name: "k"}, {Name: "k"}}}}}, "[a] k: duplicate key"},
+		{&Schema{Sections: []SectionSchema{{Name: "a", Keys: []KeySchema{{Name: "k", Pattern: "("}}}}}, "[a] k: pattern"},
+		{&Schema{Sections: []SectionSchema{{Name: "a", Keys: []KeySchema{{Name: "k", Type: TypeInt, Min: "0x1"}}}}}, "[a] k: min"},
+		{&Schema{Sections: []SectionSchema{{Name: "a", Keys: []KeySchema{{Name: "k", Type: TypeBool, Max: "1"}}}}}, "[a] k: max"},
+	}
+	c := mustLoad(t, "[a]\nk = 1\n")
+	for _, tt := range tests {
+		if _, err := c.Validate(tt.schema); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("got %v, want %q", err, tt.err)
+		}
+	}
+}
+
+func TestSchemaOnLoad(t *testing.T) {
+	c := New()
+	c.SetSchema(testSchema())
+	if err := c.LoadString("[db]\ndsn = root@tcp\nmaxconn = 10\n"); err != nil {
+		t.Fatal(err)
+	}
+	err := c.LoadString("[db]\ndsn = root@tcp\nmaxconn = 5000\n")
+	if !errors.As(err, new(ValidationErrors)) {
+		t.Fatalf("got %v", err)
+	}
+	if c.GetValue("db", "maxconn") != "10" {
+		t.Error("invalid load replaced the config")
+	}
+	if err := c.LoadString("[db]\ndsn = root@tcp\nmaxcon = 1\n"); err != nil {
+		t.Fatal(err)
+	}
+	if w := c.Warnings(); len(w) != 1 || !strings.Contains(w[0].Reason, "unknown key") {
+		t.Errorf("warnings: %v", w)
+	}
+}
+
+func TestSchemaMasksSecrets(t *testing.T) {
+	unsetEnv(t, SecretKeyEnv, SecretKeyFileEnv)
+	dir := writeFiles(t, map[string]string{"dsn": "hunter2-file"})
+	dsn, _ := EncryptValue("hunter2-enc", []byte("master"))
+	maxconn, _ := EncryptValue("hunter2-int", []byte("master"))
+	for _, text := range []string{
+		"[db]\ndsn = " + dsn + "\n",
+		"[db]\ndsn = @file:" + filepath.Join(dir, "dsn") + "\n",
+		"[db]\ndsn = root@tcp\nmaxconn = " + maxconn + "\n",
+	} {
+		c := New()
+		c.SetSecretKey([]byte("master"))
+		c.SetSchema(testSchema())
+		err := c.LoadString(text)
+		if !errors.As(err, new(ValidationErrors)) {
+			t.Fatalf("got %v", err)
+		}
+		if strings.Contains(err.Error(), "hunter2") || !strings.Contains(err.Error(), Mask) {
+			t.Errorf("secret in error: %v", err)
+		}
+	}
+}
+
+func TestSchemaJSON(t *testing.T) {
+	var s Schema
+	err := json.Unmarshal([]byte(`{"Sections": [{"Name": "db", "Keys": [{"Name": "timeout", "Type": "duration"}, {"Name": "dsn", "Type": ""}]}]}`), &s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Sections[0].Keys[0].Type != TypeDuration || s.Sections[0].Keys[1].Type != TypeString {
+		t.Errorf("%+v", s.Sections[0].Keys)
+	}
+	if err := json.Unmarshal([]byte(`{"Sections": [{"Keys": [{"Type": "number"}]}]}`), &s); err == nil {
+		t.Error("unknown type accepted")
+	}
+}
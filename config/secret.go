@@ -0,0 +1,164 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/go-irain/tools/aes"
+)
+
+// 加密保存的值写作 ENC(base64) 加载时使用主密钥解密
+// 主密钥按顺序查找 SetSecretKey设置的值 环境变量CONFIG_SECRET_KEY
+// 环境变量CONFIG_SECRET_KEY_FILE指定的文件
+const (
+	SecretKeyEnv     = "CONFIG_SECRET_KEY"
+	SecretKeyFileEnv = "CONFIG_SECRET_KEY_FILE"
+)
+
+// Mask 输出配置时代替加密值的内容
+const Mask = "******"
+
+// cryptBlockSize aes的块大小 密文的长度是它的整数倍
+const cryptBlockSize = 16
+
+// ErrNoSecretKey 有加密的值但是找不到主密钥
+var ErrNoSecretKey = errors.New("config: encrypted value found but no secret key, set " + SecretKeyEnv + " or " + SecretKeyFileEnv)
+
+// IsEncrypted 值是否是ENC(...)的形式
+func IsEncrypted(s string) bool {
+	return strings.HasPrefix(s, "ENC(") && strings.HasSuffix(s, ")")
+}
+
+// EncryptValue 加密一个值 返回ENC(base64)
+func EncryptValue(plain string, key []byte) (string, error) {
+	crypted, err := aes.AesEncrypt([]byte(plain), key)
+	if err != nil {
+		return "", err
+	}
+	return "ENC(" + base64.StdEncoding.EncodeToString(crypted) + ")", nil
+}
+
+// DecryptValue 解密ENC(base64)形式的值
+func DecryptValue(s string, key []byte) (string, error) {
+	if !IsEncrypted(s) {
+		return "", errors.New("not an ENC(...) value")
+	}
+	crypted, err := base64.StdEncoding.DecodeString(s[4 : len(s)-1])
+	if err != nil {
+		return "", fmt.Errorf("bad base64: %v", err)
+	}
+	// AesDecrypt不检查长度 长度不对时会panic
+	if len(crypted) == 0 || len(crypted)%cryptBlockSize != 0 {
+		return "", fmt.Errorf("bad length %d", len(crypted))
+	}
+	// 填充不对时AesDecrypt返回nil 没有错误 明文为空时也有一个块的填充 不会是nil
+	plain, err := aes.AesDecrypt(crypted, key)
+	if err != nil || plain == nil {
+		return "", errors.New("decrypt failed, wrong key or corrupted value")
+	}
+	return string(plain), nil
+}
+
+// ReadSecretKey 从环境变量或者环境变量指定的文件读取主密钥 都没有时返回nil
+func ReadSecretKey() ([]byte, error) {
+	if key := os.Getenv(SecretKeyEnv); key != "" {
+		return []byte(key), nil
+	}
+	if name := os.Getenv(SecretKeyFileEnv); name != "" {
+		return ReadSecretKeyFile(name)
+	}
+	return nil, nil
+}
+
+// ReadSecretKeyFile 从文件读取主密钥 去掉末尾的换行
+func ReadSecretKeyFile(name string) ([]byte, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	key := bytes.TrimRight(data, "\r\n")
+	if len(key) == 0 {
+		return nil, fmt.Errorf("config: secret key file %s is empty", name)
+	}
+	return key, nil
+}
+
+// SetSecretKey 设置解密用的主密钥 对之后的Load生效
+func (c *Config) SetSecretKey(key []byte) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.secretkey = append([]byte{}, key...)
+}
+
+// SetSecretKey 设置默认配置的主密钥
+func SetSecretKey(key []byte) {
+	defConfig.SetSecretKey(key)
+}
+
+// SetKeepEncrypted 开启后加载时不解密ENC(...)的值 原样保留并标记为secret 对之后的Load生效
+// 用于只查看配置的工具 没有主密钥时也可以加载
+func (c *Config) SetKeepEncrypted(keep bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.keepencrypted = keep
+}
+
+// decrypt 解密snap中全部ENC(...)的值 并标记为secret
+func (c *Config) decrypt(snap *snapshot) error {
+	c.lock.Lock()
+	key, keep := c.secretkey, c.keepencrypted
+	c.lock.Unlock()
+	for sec, values := range snap.file {
+		for k, list := range values {
+			for i, val := range list {
+				if !IsEncrypted(val) {
+					continue
+				}
+				if keep {
+					snap.markSecret(sec, k)
+					continue
+				}
+				if key == nil {
+					var err error
+					if key, err = ReadSecretKey(); err != nil {
+						return err
+					}
+					if key == nil {
+						return ErrNoSecretKey
+					}
+				}
+				plain, err := DecryptValue(val, key)
+				if err != nil {
+					return fmt.Errorf("config: %s: [%s] %s: %v", snap.origins[sec][k][i], sec, k, err)
+				}
+				list[i] = plain
+				snap.markSecret(sec, k)
+			}
+		}
+	}
+	return nil
+}
+
+// markSecret 标记key的值需要隐藏
+func (s *snapshot) markSecret(sec, key string) {
+	if s.secrets[sec] == nil {
+		s.secrets[sec] = make(map[string]bool)
+	}
+	s.secrets[sec][key] = true
+}
+
+// IsSecret key的值是否是加密保存的 输出时应该隐藏
+func (c *Config) IsSecret(sec, key string) bool {
+	snap := c.snapshot()
+	return snap != nil && snap.secrets[sec][key]
+}
+
+// IsSecret 默认配置的key是否是加密保存的
+func IsSecret(sec, key string) bool {
+	return defConfig.IsSecret(sec, key)
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+// unsetEnv 测试期间去掉环境变量 结束后恢复
+func unsetEnv(t *testing.T, names ...string) {
+	for _, name := range names {
+		if old, ok := os.LookupEnv(name); ok {
+			os.Unsetenv(name)
+			t.Cleanup(func() { os.Setenv(name, old) })
+		}
+	}
+}
+
+func TestSecretValue(t *testing.T) {
+	key := []byte("master")
+	for _, plain := range []string{"", "p@ss", strings.Repeat("x", 100)} {
+		enc, err := EncryptValue(plain, key)
+		if err != nil || !IsEncrypted(enc) {
+			t.Fatalf("EncryptValue = %q, %v", enc, err)
+		}
+		if got, err := DecryptValue(enc, key); err != nil || got != plain {
+			t.Errorf("DecryptValue = %q, %v", got, err)
+		}
+	}
+	enc, _ := EncryptValue("p@ss", key)
+	if _, err := DecryptValue(enc, []byte("other")); err == nil {
+		t.Error("wrong key accepted")
+	}
+	bad := []string{
+		"p@ss",
+		"ENC(!!)",
+		"ENC()",
+		"ENC(" + base64.StdEncoding.EncodeToString([]byte("short")) + ")",
+		"ENC(" + base64.StdEncoding.EncodeToString(make([]byte, 17)) + ")",
+	}
+	for _, s := range bad {
+		if _, err := DecryptValue(s, key); err == nil {
+			t.Errorf("%q accepted", s)
+		}
+	}
+}
+
+func TestDecryptOnLoad(t *testing.T) {
+	unsetEnv(t, SecretKeyEnv, SecretKeyFileEnv)
+	key := []byte("master")
+	enc, _ := EncryptValue("p@ss", key)
+	text := "[db]\nuser = root\npassword = " + enc + "\n"
+
+	c := New()
+	if err := c.LoadString(text); !errors.Is(err, ErrNoSecretKey) {
+		t.Fatalf("no key: got %v", err)
+	}
+
+	c.SetSecretKey([]byte("other"))
+	err := c.LoadString(text)
+	if err == nil || !strings.Contains(err.Error(), "line 3: [db] password") {
+		t.Fatalf("wrong key: got %v", err)
+	}
+
+	os.Setenv(SecretKeyEnv, string(key))
+	defer os.Unsetenv(SecretKeyEnv)
+	c = New()
+	if err := c.LoadString(text); err != nil {
+		t.Fatal(err)
+	}
+	if c.GetValue("db", "password") != "p@ss" || !c.IsSecret("db", "password") || c.IsSecret("db", "user") {
+		t.Errorf("password = %q", c.GetValue("db", "password"))
+	}
+	var buf bytes.Buffer
+	if err := c.Dump(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if want := "[db]\npassword = " + Mask + "\nuser = root\n"; buf.String() != want {
+		t.Errorf("dump:\n%s", buf.String())
+	}
+}
+
+func TestKeepEncrypted(t *testing.T) {
+	unsetEnv(t, SecretKeyEnv, SecretKeyFileEnv)
+	enc, _ := EncryptValue("p@ss", []byte("master"))
+	c := New()
+	c.SetKeepEncrypted(true)
+	if err := c.LoadString("[db]\npassword = " + enc + "\n"); err != nil {
+		t.Fatal(err)
+	}
+	if c.GetValue("db", "password") != enc || !c.IsSecret("db", "password") {
+		t.Errorf("password = %q", c.GetValue("db", "password"))
+	}
+}
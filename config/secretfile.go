@@ -0,0 +1,165 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 值写作 @file:/run/secrets/db_pass 时加载时读取文件的内容代替
+// 启用KeySuffix后 password_file = /run/secrets/db_pass 会设置同一块中的password
+// 每次加载和Reload都重新读取 文件会加入Watch的检查列表
+//
+//	[db]
+//	password = @file:/run/secrets/db_pass
+//
+// 相对路径相对于值所在的配置文件 来自环境变量和命令行参数时相对于当前目录
+// 读取的值标记为secret 输出时隐藏
+
+// FilePrefix 引用文件的值的前缀
+const FilePrefix = "@file:"
+
+// DefaultFileMaxSize 默认允许的文件大小
+const DefaultFileMaxSize = 64 << 10
+
+// FileOptions 读取引用文件的设置
+type FileOptions struct {
+	// MaxSize 文件大小的上限 默认为DefaultFileMaxSize
+	MaxSize int64
+	// DenyMode 文件权限中不允许出现的位 默认0022 即组和其他用户不能写
+	// 要求其他用户不能读时使用0027
+	DenyMode os.FileMode
+	// KeySuffix 如"_file" key带有这个后缀时读取文件设置去掉后缀的key 为空时不启用
+	KeySuffix string
+}
+
+// IsFileRef 值是否是@file:的形式
+func IsFileRef(s string) bool {
+	return strings.HasPrefix(s, FilePrefix)
+}
+
+// SetFileOptions 设置引用文件的读取方式 对之后的Load生效
+func (c *Config) SetFileOptions(opts FileOptions) {
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = DefaultFileMaxSize
+	}
+	if opts.DenyMode == 0 {
+		opts.DenyMode = 0022
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.fileopts = &opts
+}
+
+// SetFileOptions 设置默认配置引用文件的读取方式
+func SetFileOptions(opts FileOptions) {
+	defConfig.SetFileOptions(opts)
+}
+
+// readRefs 把snap中@file:和KeySuffix的值替换为文件的内容
+func (c *Config) readRefs(snap *snapshot) error {
+	c.lock.Lock()
+	opts := c.fileopts
+	c.lock.Unlock()
+	if opts == nil {
+		opts = &FileOptions{MaxSize: DefaultFileMaxSize, DenyMode: 0022}
+	}
+	for sec, values := range snap.file {
+		for key, list := range values {
+			for i, val := range list {
+				if !IsFileRef(val) {
+					continue
+				}
+				at := snap.origins[sec][key][i]
+				data, name, err := opts.read(val[len(FilePrefix):], at)
+				if err != nil {
+					return fmt.Errorf("config: %s: [%s] %s: %v", at, sec, key, err)
+				}
+				list[i] = data
+				snap.markSecret(sec, key)
+				snap.addFile(name)
+			}
+		}
+		if opts.KeySuffix == "" {
+			continue
+		}
+		// 先找出全部带后缀的key 遍历时会添加新的key
+		var keys []string
+		for key := range values {
+			if strings.HasSuffix(key, opts.KeySuffix) && len(key) > len(opts.KeySuffix) {
+				keys = append(keys, key)
+			}
+		}
+		for _, key := range keys {
+			list := values[key]
+			target := strings.TrimSuffix(key, opts.KeySuffix)
+			if _, has := values[target]; has {
+				return fmt.Errorf("config: %s: [%s] both %s and %s are set", snap.origins[sec][key][0], sec, target, key)
+			}
+			vals := make([]string, len(list))
+			for i, val := range list {
+				at := snap.origins[sec][key][i]
+				data, name, err := opts.read(val, at)
+				if err != nil {
+					return fmt.Errorf("config: %s: [%s] %s: %v", at, sec, key, err)
+				}
+				vals[i] = data
+				snap.addFile(name)
+			}
+			values[target] = vals
+			snap.origins[sec][target] = append([]Origin{}, snap.origins[sec][key]...)
+			snap.markSecret(sec, target)
+		}
+	}
+	return nil
+}
+
+// read 检查并读取文件 去掉末尾的一个\n或\r\n 返回内容和文件的绝对路径
+func (o *FileOptions) read(name string, at Origin) (string, string, error) {
+	if name == "" {
+		return "", "", fmt.Errorf("empty file name")
+	}
+	if !filepath.IsAbs(name) && at.File != "" && at.Env == "" && at.Flag == "" && !strings.Contains(at.File, "://") {
+		name = filepath.Join(filepath.Dir(at.File), name)
+	}
+	if abs, err := filepath.Abs(name); err == nil {
+		name = abs
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return "", "", err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return "", "", err
+	}
+	if !st.Mode().IsRegular() {
+		return "", "", fmt.Errorf("%s is not a regular file", name)
+	}
+	if perm := st.Mode().Perm(); perm&o.DenyMode != 0 {
+		return "", "", fmt.Errorf("%s has insecure permissions %#o", name, perm)
+	}
+	if st.Size() > o.MaxSize {
+		return "", "", fmt.Errorf("%s is larger than %d bytes", name, o.MaxSize)
+	}
+	// 读取时再限制一次 文件可能在Stat之后变大
+	data, err := ioutil.ReadAll(io.LimitReader(f, o.MaxSize+1))
+	if err != nil {
+		return "", "", err
+	}
+	if int64(len(data)) > o.MaxSize {
+		return "", "", fmt.Errorf("%s is larger than %d bytes", name, o.MaxSize)
+	}
+	// 只去掉最后一个换行 内容本身末尾的空行保留
+	if bytes.HasSuffix(data, []byte("\r\n")) {
+		data = data[:len(data)-2]
+	} else if bytes.HasSuffix(data, []byte("\n")) {
+		data = data[:len(data)-1]
+	}
+	return string(data), name, nil
+}
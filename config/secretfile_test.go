@@ -0,0 +1,110 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestFileRef(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"secrets/db_pass": "p@ss\n",
+		"secrets/api_key": "k1",
+		"app.ini": "[db]\npassword = @file:secrets/db_pass\nagain = @file:secrets/db_pass\n" +
+			"[api]\nkey_file = secrets/api_key\n",
+	})
+	c := New()
+	c.SetFileOptions(FileOptions{KeySuffix: "_file"})
+	if err := c.LoadFile(filepath.Join(dir, "app.ini")); err != nil {
+		t.Fatal(err)
+	}
+	if c.GetValue("db", "password") != "p@ss" || !c.IsSecret("db", "password") {
+		t.Errorf("password = %q", c.GetValue("db", "password"))
+	}
+	if c.GetValue("api", "key") != "k1" || !c.IsSecret("api", "key") {
+		t.Errorf("api = %v", c.GetSection("api"))
+	}
+	// 同一个文件只加入一次Watch的检查列表
+	want := []string{filepath.Join(dir, "app.ini"), filepath.Join(dir, "secrets", "db_pass"), filepath.Join(dir, "secrets", "api_key")}
+	files := append([]string{}, c.snapshot().files...)
+	sort.Strings(files)
+	sort.Strings(want)
+	if strings.Join(files, ",") != strings.Join(want, ",") {
+		t.Errorf("files %v", files)
+	}
+}
+
+func TestFileRefNewline(t *testing.T) {
+	files := map[string]string{
+		"lf":    "a\n",
+		"crlf":  "a\r\n",
+		"blank": "a\n\n",
+		"cr":    "a\r",
+		"none":  "a",
+	}
+	want := map[string]string{"lf": "a", "crlf": "a", "blank": "a\n", "cr": "a\r", "none": "a"}
+	dir := writeFiles(t, files)
+	for name, w := range want {
+		c := mustLoad(t, "[a]\nk = @file:"+filepath.Join(dir, name)+"\n")
+		if got := c.GetValue("a", "k"); got != w {
+			t.Errorf("%s: got %q, want %q", name, got, w)
+		}
+	}
+}
+
+func TestFileRefErrors(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"open":  "x",
+		"big":   strings.Repeat("x", 100),
+		"pass":  "x",
+		"other": "x",
+	})
+	if err := os.Chmod(filepath.Join(dir, "open"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		text, err string
+	}{
+		{"[a]\nk = @file:" + filepath.Join(dir, "none") + "\n", "line 2: [a] k:"},
+		{"[a]\nk = @file:" + filepath.Join(dir, "open") + "\n", "insecure permissions"},
+		{"[a]\nk = @file:" + filepath.Join(dir, "big") + "\n", "larger than 10 bytes"},
+		{"[a]\nk = @file:" + dir + "\n", "not a regular file"},
+		{"[a]\npass = x\npass_file = " + filepath.Join(dir, "pass") + "\n", "both pass and pass_file are set"},
+	}
+	for _, tt := range tests {
+		c := New()
+		c.SetFileOptions(FileOptions{MaxSize: 10, KeySuffix: "_file"})
+		if err := c.LoadString(tt.text); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("got %v, want %q", err, tt.err)
+		}
+	}
+}
+
+func TestSecretRefs(t *testing.T) {
+	unsetEnv(t, SecretKeyEnv, SecretKeyFileEnv)
+	dir := writeFiles(t, map[string]string{"db_pass": "p@ss"})
+	enc, _ := EncryptValue("e@ss", []byte("master"))
+	base := "[db]\npassword = @file:" + filepath.Join(dir, "db_pass") + "\ntoken = " + enc + "\n"
+
+	// 整个值引用时 复制的值再读取或解密 并标记为secret
+	c := New()
+	c.SetExtendedSyntax(true)
+	c.SetSecretKey([]byte("master"))
+	if err := c.LoadString(base + "[app]\npass = ${db.password}\ntoken = ${db.token}\n"); err != nil {
+		t.Fatal(err)
+	}
+	if c.GetValue("app", "pass") != "p@ss" || c.GetValue("app", "token") != "e@ss" ||
+		!c.IsSecret("app", "pass") || !c.IsSecret("app", "token") {
+		t.Errorf("app = %v", c.GetSection("app"))
+	}
+
+	// 拼接在其他值中时不能解密 加载失败而不是使用@file:或者ENC(...)的文本
+	for _, ref := range []string{"db.password", "db.token"} {
+		err := c.LoadString(base + "[app]\ndsn = root:${" + ref + "}@tcp\n")
+		if err == nil || !strings.Contains(err.Error(), "["+"app] dsn: ${"+ref+"} is an ENC() or @file: secret") {
+			t.Errorf("%s: got %v", ref, err)
+		}
+	}
+}
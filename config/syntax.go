@@ -0,0 +1,229 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// 扩展语法 通过SetExtendedSyntax开启 默认关闭 以前的文件解析结果不变
+//
+//	[db]                          ; 行尾注释 ;和#前面需要有空白
+//	password = "  a#b;c  "        # 双引号 支持\n \t \" \\ \xHH \uXXXX等转义
+//	pattern  = '^\d+$'            # 单引号 内容原样保留
+//	empty    = ""                 # 明确的空值
+//	query    = select * \
+//	           from t             # 行尾的\把下一行接到当前行
+//	cert     = <<EOF              # heredoc 直到单独一行的EOF为止
+//	-----BEGIN CERTIFICATE-----
+//	...
+//	EOF
+//	sql      = <<-SQL             # <<- 去掉每行共同的缩进
+//	    select *
+//	    from t
+//	    SQL
+//	hosts   += 10.0.0.3           # 追加到include或下层文件的数组 而不是替换
+//
+// 块继承[child : parent]和块引用${sec.key}也属于扩展语法 见inherit.go
+
+// SetExtendedSyntax 开启或关闭扩展语法 对之后的Load生效
+func (c *Config) SetExtendedSyntax(on bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.extended = on
+}
+
+// SetExtendedSyntax 设置默认配置是否使用扩展语法
+func SetExtendedSyntax(on bool) {
+	defConfig.SetExtendedSyntax(on)
+}
+
+// trailingBackslash 行尾是否是没有被转义的\
+func trailingBackslash(s string) bool {
+	n := 0
+	for i := len(s) - 1; i >= 0 && s[i] == '\\'; i-- {
+		n++
+	}
+	return n%2 == 1
+}
+
+// joinContinuation 处理行尾的\ 把后面的行拼接到当前行
+func joinContinuation(lr *lineReader, line string) (string, error) {
+	for {
+		trimmed := strings.TrimRight(line, " \t")
+		if !trailingBackslash(trimmed) {
+			return line, nil
+		}
+		next, ok, err := lr.next()
+		if err != nil {
+			return "", err
+		}
+		line = trimmed[:len(trimmed)-1]
+		if !ok {
+			return line, nil
+		}
+		line += strings.TrimLeft(next, " \t")
+	}
+}
+
+// stripInlineComment 去掉行尾注释 ;和#前面需要有空白 避免影响a#b这样的值
+func stripInlineComment(s string) string {
+	for i := 1; i < len(s); i++ {
+		if (s[i] == ';' || s[i] == '#') && (s[i-1] == ' ' || s[i-1] == '\t') {
+			return strings.TrimSpace(s[:i])
+		}
+	}
+	return strings.TrimSpace(s)
+}
+
+// parseValue 按扩展语法解析等号右边的内容
+// explicit为true表示值来自引号或heredoc 空字符串也是有效的值
+func parseValue(lr *lineReader, raw string) (val string, explicit bool, err error) {
+	if raw == "" {
+		return "", false, nil
+	}
+	switch raw[0] {
+	case '"', '\'':
+		val, rest, err := unquote(raw)
+		if err != nil {
+			return "", false, err
+		}
+		if rest = strings.TrimSpace(rest); rest != "" && rest[0] != ';' && rest[0] != '#' {
+			return "", false, fmt.Errorf("unexpected %q after quoted value", rest)
+		}
+		return val, true, nil
+	case '<':
+		// 先去掉行尾注释 <<EOF  # 说明 也是heredoc
+		if tag, strip, ok := heredocTag(stripInlineComment(raw)); ok {
+			val, err := readHeredoc(lr, tag, strip)
+			return val, true, err
+		}
+	}
+	return stripInlineComment(raw), false, nil
+}
+
+// unquote 解析开头的引号字符串 返回内容和引号后面剩下的部分
+func unquote(s string) (string, string, error) {
+	quote := s[0]
+	if quote == '\'' {
+		end := strings.IndexByte(s[1:], '\'')
+		if end < 0 {
+			return "", "", errors.New("unterminated single-quoted value")
+		}
+		return s[1 : end+1], s[end+2:], nil
+	}
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"':
+			return b.String(), s[i+1:], nil
+		case c != '\\':
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		if i >= len(s) {
+			break
+		}
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case '0':
+			b.WriteByte(0)
+		case '\\', '"', '\'', ';', '#', '$':
+			b.WriteByte(s[i])
+		case 'x', 'u', 'U':
+			size := 2
+			if s[i] == 'u' {
+				size = 4
+			} else if s[i] == 'U' {
+				size = 8
+			}
+			if i+size >= len(s) {
+				return "", "", fmt.Errorf("invalid escape \\%c", s[i])
+			}
+			n, err := strconv.ParseUint(s[i+1:i+1+size], 16, 32)
+			if err != nil {
+				return "", "", fmt.Errorf("invalid escape \\%s", s[i:i+1+size])
+			}
+			if s[i] == 'x' {
+				b.WriteByte(byte(n))
+			} else if !utf8.ValidRune(rune(n)) {
+				return "", "", fmt.Errorf("invalid escape \\%s", s[i:i+1+size])
+			} else {
+				b.WriteRune(rune(n))
+			}
+			i += size
+		default:
+			return "", "", fmt.Errorf("invalid escape \\%c", s[i])
+		}
+	}
+	return "", "", errors.New("unterminated double-quoted value")
+}
+
+// heredocTag 判断是否是<<TAG或者<<-TAG TAG由字母数字和_组成
+func heredocTag(s string) (tag string, strip bool, ok bool) {
+	if !strings.HasPrefix(s, "<<") {
+		return "", false, false
+	}
+	tag = s[2:]
+	if strings.HasPrefix(tag, "-") {
+		tag, strip = tag[1:], true
+	}
+	if tag == "" {
+		return "", false, false
+	}
+	for i := 0; i < len(tag); i++ {
+		c := tag[i]
+		if !(c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || i > 0 && c >= '0' && c <= '9') {
+			return "", false, false
+		}
+	}
+	return tag, strip, true
+}
+
+// readHeredoc 读取heredoc的内容 直到单独一行的tag
+func readHeredoc(lr *lineReader, tag string, strip bool) (string, error) {
+	lines := []string{}
+	for {
+		line, ok, err := lr.next()
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			return "", fmt.Errorf("unterminated heredoc <<%s", tag)
+		}
+		if strings.TrimSpace(line) == tag {
+			break
+		}
+		lines = append(lines, line)
+	}
+	if strip {
+		// 去掉非空行共同的缩进
+		indent := -1
+		for _, line := range lines {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			n := len(line) - len(strings.TrimLeft(line, " \t"))
+			if indent < 0 || n < indent {
+				indent = n
+			}
+		}
+		for i, line := range lines {
+			if len(line) >= indent && indent > 0 {
+				lines[i] = line[indent:]
+			} else {
+				lines[i] = strings.TrimLeft(line, " \t")
+			}
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
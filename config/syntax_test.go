@@ -0,0 +1,126 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+const syntaxFile = `[db] ; comment
+password = "  a#b;c  "   # comment
+escaped  = "tab\there\nnl \"q\" \x41中"
+pattern  = '^\d+$'
+empty    = ""
+hash     = a#b ;comment
+query    = select * \
+           from t
+cert     = <<EOF
+line1
+  line2
+EOF
+sql      = <<-SQL
+    select *
+      from t
+    SQL
+`
+
+func TestExtendedSyntax(t *testing.T) {
+	c := New()
+	c.SetExtendedSyntax(true)
+	if err := c.LoadString(syntaxFile); err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]string{
+		"password": "  a#b;c  ",
+		"escaped":  "tab\there\nnl \"q\" A中",
+		"pattern":  `^\d+$`,
+		"empty":    "",
+		"hash":     "a#b",
+		"query":    "select * from t",
+		"cert":     "line1\n  line2",
+		"sql":      "select *\n  from t",
+	}
+	sec := c.GetSection("db")
+	if sec == nil {
+		t.Fatal("inline comment after the header broke the section")
+	}
+	for key, want := range tests {
+		vals, ok := sec[key]
+		if !ok || len(vals) != 1 || vals[0] != want {
+			t.Errorf("%s = %q, want %q", key, vals, want)
+		}
+	}
+	if w := c.Warnings(); len(w) != 0 {
+		t.Errorf("warnings: %v", w)
+	}
+	if o, _ := c.Origin("db", "sql"); o.Line != 13 {
+		t.Errorf("heredoc origin %v", o)
+	}
+}
+
+func TestHeredocComment(t *testing.T) {
+	c := New()
+	c.SetExtendedSyntax(true)
+	err := c.LoadString(`[tls]
+cert = <<EOF  # heredoc
+line1
+EOF
+key  = <<-KEY	; indented
+    line2
+    KEY
+next = x
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.GetValue("tls", "cert"); got != "line1" {
+		t.Errorf("cert = %q", got)
+	}
+	if got := c.GetValue("tls", "key"); got != "line2" {
+		t.Errorf("key = %q", got)
+	}
+	if c.GetValue("tls", "next") != "x" || len(c.Warnings()) != 0 {
+		t.Errorf("tls = %v, warnings %v", c.GetSection("tls"), c.Warnings())
+	}
+}
+
+func TestDefaultSyntaxUnchanged(t *testing.T) {
+	// 默认模式下引号 注释和\都是值的一部分
+	c := mustLoad(t, `[db]
+password = "a b" ; not a comment
+query = a \
+empty = ""
+`)
+	tests := map[string]string{
+		"password": `"a b" ; not a comment`,
+		"query":    `a \`,
+		"empty":    `""`,
+	}
+	for key, want := range tests {
+		if got := c.GetValue("db", key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestExtendedSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		text   string
+		reason string
+	}{
+		{`a = "open`, "unterminated double-quoted"},
+		{`a = 'open`, "unterminated single-quoted"},
+		{`a = "x" y`, "unexpected"},
+		{`a = "\q"`, "invalid escape"},
+		{`a = "\u12"`, "invalid escape"},
+		{"a = <<EOF\nno end", "unterminated heredoc"},
+	}
+	for _, tt := range tests {
+		c := New()
+		c.SetExtendedSyntax(true)
+		c.SetStrict(true)
+		err := c.LoadString("[s]\n" + tt.text + "\n")
+		if err == nil || !strings.Contains(err.Error(), tt.reason) {
+			t.Errorf("%q: got %v, want %q", tt.text, err, tt.reason)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+// Package toml 注册.toml格式的配置文件
+//
+//	import _ "github.com/go-irain/tools/config/toml"
+//
+// 导入后config.LoadFile按扩展名解析toml文件
+// 表对应块 嵌套的表使用.连接的块名 [[servers]]对应[servers.0] [servers.1]
+package toml
+
+import (
+	"io"
+
+	"github.com/BurntSushi/toml"
+	"github.com/go-irain/tools/config"
+)
+
+func init() {
+	config.RegisterFormat(".toml", decode)
+}
+
+func decode(r io.Reader) (map[string]interface{}, error) {
+	var tree map[string]interface{}
+	if _, err := toml.NewDecoder(r).Decode(&tree); err != nil {
+		return nil, err
+	}
+	if tree == nil {
+		tree = make(map[string]interface{})
+	}
+	// [[servers]]解析为[]map[string]interface{} 转换为通用的数组
+	return normalize(tree).(map[string]interface{}), nil
+}
+
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, e := range v {
+			v[key] = normalize(e)
+		}
+		return v
+	case []map[string]interface{}:
+		list := make([]interface{}, len(v))
+		for i, e := range v {
+			list[i] = normalize(e)
+		}
+		return list
+	case []interface{}:
+		for i, e := range v {
+			v[i] = normalize(e)
+		}
+		return v
+	}
+	return v
+}
@@ -0,0 +1,476 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrMissing key不存在
+var ErrMissing = errors.New("key is missing")
+
+// ValueError 类型转换失败或者key不存在时返回的错误
+type ValueError struct {
+	Section string
+	Key     string
+	Value   string
+	Type    string
+	Err     error
+}
+
+func (e *ValueError) Error() string {
+	where := e.Key
+	if e.Section != "" {
+		where = "[" + e.Section + "] " + e.Key
+	}
+	if e.Err == ErrMissing {
+		return "config: " + where + ": " + ErrMissing.Error()
+	}
+	return fmt.Sprintf("config: %s = %q: invalid %s: %v", where, e.Value, e.Type, e.Err)
+}
+
+// Unwrap 支持errors.Is(err, ErrMissing)
+func (e *ValueError) Unwrap() error {
+	return e.Err
+}
+
+// Value 一个key对应的所有值 提供类型转换
+// 带默认值的方法在key不存在或者转换失败时返回默认值
+// 以E结尾的方法返回*ValueError 复数形式的方法转换重复key组成的数组
+type Value struct {
+	Section string
+	Key     string
+	Values  []string
+}
+
+// View 把块作为名为sec的只读视图 通过视图的Lookup获取的值错误信息中包含块名
+//
+//	port, err := c.GetSection("db").View("db").Lookup("port").IntE()
+func (m ConfigSection) View(sec string) SectionView {
+	return SectionView{name: sec, m: m}
+}
+
+// Lookup 获取[sec]中key对应的值
+func (c *Config) Lookup(sec, key string) Value {
+	return c.View().Lookup(sec, key)
+}
+
+// Lookup 获取默认配置[sec]中key对应的值
+func Lookup(sec, key string) Value {
+	return defConfig.Lookup(sec, key)
+}
+
+// Exists key是否存在
+func (v Value) Exists() bool {
+	return len(v.Values) > 0
+}
+
+// String 返回第一个值 不存在返回空字符串
+func (v Value) String() string {
+	if len(v.Values) > 0 {
+		return v.Values[0]
+	}
+	return ""
+}
+
+func (v Value) errorf(typ, raw string, err error) error {
+	return &ValueError{Section: v.Section, Key: v.Key, Value: raw, Type: typ, Err: err}
+}
+
+// first 返回第一个值 不存在返回ErrMissing
+func (v Value) first(typ string) (string, error) {
+	if len(v.Values) == 0 {
+		return "", v.errorf(typ, "", ErrMissing)
+	}
+	return v.Values[0], nil
+}
+
+// each 对每个值调用parse 第一个失败的值作为错误返回
+func (v Value) each(typ string, parse func(string) error) error {
+	for _, raw := range v.Values {
+		if err := parse(raw); err != nil {
+			return v.errorf(typ, raw, err)
+		}
+	}
+	return nil
+}
+
+// numError 去掉strconv错误中重复的函数名和原始值
+func numError(err error) error {
+	if ne, ok := err.(*strconv.NumError); ok {
+		return ne.Err
+	}
+	return err
+}
+
+// IntE 转换为int
+func (v Value) IntE() (int, error) {
+	raw, err := v.first("int")
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, v.errorf("int", raw, numError(err))
+	}
+	return n, nil
+}
+
+// Int 转换为int 失败返回def
+func (v Value) Int(def int) int {
+	if n, err := v.IntE(); err == nil {
+		return n
+	}
+	return def
+}
+
+// Ints 把所有值转换为int
+func (v Value) Ints() ([]int, error) {
+	out := make([]int, 0, len(v.Values))
+	err := v.each("int", func(raw string) error {
+		n, err := strconv.Atoi(raw)
+		out = append(out, n)
+		return numError(err)
+	})
+	return out, err
+}
+
+// Int64E 转换为int64
+func (v Value) Int64E() (int64, error) {
+	raw, err := v.first("int64")
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return 0, v.errorf("int64", raw, numError(err))
+	}
+	return n, nil
+}
+
+// Int64 转换为int64 失败返回def
+func (v Value) Int64(def int64) int64 {
+	if n, err := v.Int64E(); err == nil {
+		return n
+	}
+	return def
+}
+
+// Int64s 把所有值转换为int64
+func (v Value) Int64s() ([]int64, error) {
+	out := make([]int64, 0, len(v.Values))
+	err := v.each("int64", func(raw string) error {
+		n, err := strconv.ParseInt(raw, 10, 64)
+		out = append(out, n)
+		return numError(err)
+	})
+	return out, err
+}
+
+// UintE 转换为uint
+func (v Value) UintE() (uint, error) {
+	raw, err := v.first("uint")
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseUint(raw, 10, 0)
+	if err != nil {
+		return 0, v.errorf("uint", raw, numError(err))
+	}
+	return uint(n), nil
+}
+
+// Uint 转换为uint 失败返回def
+func (v Value) Uint(def uint) uint {
+	if n, err := v.UintE(); err == nil {
+		return n
+	}
+	return def
+}
+
+// Uints 把所有值转换为uint
+func (v Value) Uints() ([]uint, error) {
+	out := make([]uint, 0, len(v.Values))
+	err := v.each("uint", func(raw string) error {
+		n, err := strconv.ParseUint(raw, 10, 0)
+		out = append(out, uint(n))
+		return numError(err)
+	})
+	return out, err
+}
+
+// FloatE 转换为float64
+func (v Value) FloatE() (float64, error) {
+	raw, err := v.first("float")
+	if err != nil {
+		return 0, err
+	}
+	f, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return 0, v.errorf("float", raw, numError(err))
+	}
+	return f, nil
+}
+
+// Float 转换为float64 失败返回def
+func (v Value) Float(def float64) float64 {
+	if f, err := v.FloatE(); err == nil {
+		return f
+	}
+	return def
+}
+
+// Floats 把所有值转换为float64
+func (v Value) Floats() ([]float64, error) {
+	out := make([]float64, 0, len(v.Values))
+	err := v.each("float", func(raw string) error {
+		f, err := strconv.ParseFloat(raw, 64)
+		out = append(out, f)
+		return numError(err)
+	})
+	return out, err
+}
+
+// ParseBool 解析布尔值 支持true/yes/on/1和false/no/off/0 不区分大小写
+func ParseBool(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "true", "yes", "on", "1":
+		return true, nil
+	case "false", "no", "off", "0":
+		return false, nil
+	}
+	return false, errors.New("need true/yes/on/1 or false/no/off/0")
+}
+
+// BoolE 转换为bool
+func (v Value) BoolE() (bool, error) {
+	raw, err := v.first("bool")
+	if err != nil {
+		return false, err
+	}
+	b, err := ParseBool(raw)
+	if err != nil {
+		return false, v.errorf("bool", raw, err)
+	}
+	return b, nil
+}
+
+// Bool 转换为bool 失败返回def
+func (v Value) Bool(def bool) bool {
+	if b, err := v.BoolE(); err == nil {
+		return b
+	}
+	return def
+}
+
+// Bools 把所有值转换为bool
+func (v Value) Bools() ([]bool, error) {
+	out := make([]bool, 0, len(v.Values))
+	err := v.each("bool", func(raw string) error {
+		b, err := ParseBool(raw)
+		out = append(out, b)
+		return err
+	})
+	return out, err
+}
+
+// DurationE 转换为time.Duration 格式同time.ParseDuration 如30s 1h30m
+func (v Value) DurationE() (time.Duration, error) {
+	raw, err := v.first("duration")
+	if err != nil {
+		return 0, err
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, v.errorf("duration", raw, err)
+	}
+	return d, nil
+}
+
+// Duration 转换为time.Duration 失败返回def
+func (v Value) Duration(def time.Duration) time.Duration {
+	if d, err := v.DurationE(); err == nil {
+		return d
+	}
+	return def
+}
+
+// Durations 把所有值转换为time.Duration
+func (v Value) Durations() ([]time.Duration, error) {
+	out := make([]time.Duration, 0, len(v.Values))
+	err := v.each("duration", func(raw string) error {
+		d, err := time.ParseDuration(raw)
+		out = append(out, d)
+		return err
+	})
+	return out, err
+}
+
+// 字节大小的单位 按1024计算
+var sizeUnits = map[string]float64{
+	"": 1, "b": 1,
+	"k": 1 << 10, "kb": 1 << 10, "kib": 1 << 10,
+	"m": 1 << 20, "mb": 1 << 20, "mib": 1 << 20,
+	"g": 1 << 30, "gb": 1 << 30, "gib": 1 << 30,
+	"t": 1 << 40, "tb": 1 << 40, "tib": 1 << 40,
+}
+
+// ParseSize 解析字节大小 如100MB 1.5g 512 单位不区分大小写 按1024计算
+func ParseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	i := 0
+	for i < len(s) && (s[i] >= '0' && s[i] <= '9' || s[i] == '.') {
+		i++
+	}
+	if i == 0 {
+		return 0, errors.New("need a number with optional unit B/KB/MB/GB/TB")
+	}
+	num, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil {
+		return 0, numError(err)
+	}
+	unit, ok := sizeUnits[strings.ToLower(strings.TrimSpace(s[i:]))]
+	if !ok {
+		return 0, fmt.Errorf("unknown size unit %q", strings.TrimSpace(s[i:]))
+	}
+	size := num * unit
+	// float64(math.MaxInt64)就是2^63 等于时转换为int64会溢出
+	if size >= math.MaxInt64 {
+		return 0, strconv.ErrRange
+	}
+	return int64(size), nil
+}
+
+// SizeE 转换为字节数
+func (v Value) SizeE() (int64, error) {
+	raw, err := v.first("size")
+	if err != nil {
+		return 0, err
+	}
+	n, err := ParseSize(raw)
+	if err != nil {
+		return 0, v.errorf("size", raw, err)
+	}
+	return n, nil
+}
+
+// Size 转换为字节数 失败返回def
+func (v Value) Size(def int64) int64 {
+	if n, err := v.SizeE(); err == nil {
+		return n
+	}
+	return def
+}
+
+// Sizes 把所有值转换为字节数
+func (v Value) Sizes() ([]int64, error) {
+	out := make([]int64, 0, len(v.Values))
+	err := v.each("size", func(raw string) error {
+		n, err := ParseSize(raw)
+		out = append(out, n)
+		return err
+	})
+	return out, err
+}
+
+// 支持的时间格式 依次尝试
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05",
+	"2006/01/02 15:04:05",
+	"2006-01-02",
+}
+
+// ParseTime 解析时间 支持RFC3339 2006-01-02 15:04:05 2006/01/02 15:04:05 和 2006-01-02
+// 没有时区的格式使用本地时区
+func ParseTime(s string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("need RFC3339 or 2006-01-02 [15:04:05]")
+}
+
+// TimeE 转换为time.Time
+func (v Value) TimeE() (time.Time, error) {
+	raw, err := v.first("time")
+	if err != nil {
+		return time.Time{}, err
+	}
+	t, err := ParseTime(raw)
+	if err != nil {
+		return time.Time{}, v.errorf("time", raw, err)
+	}
+	return t, nil
+}
+
+// Time 转换为time.Time 失败返回def
+func (v Value) Time(def time.Time) time.Time {
+	if t, err := v.TimeE(); err == nil {
+		return t
+	}
+	return def
+}
+
+// Times 把所有值转换为time.Time
+func (v Value) Times() ([]time.Time, error) {
+	out := make([]time.Time, 0, len(v.Values))
+	err := v.each("time", func(raw string) error {
+		t, err := ParseTime(raw)
+		out = append(out, t)
+		return err
+	})
+	return out, err
+}
+
+// parseURL 解析url 需要包含scheme和host
+func parseURL(s string) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		if ue, ok := err.(*url.Error); ok {
+			return nil, ue.Err
+		}
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" && u.Opaque == "" {
+		return nil, errors.New("need an absolute url")
+	}
+	return u, nil
+}
+
+// URLE 转换为*url.URL 需要是包含scheme的完整地址
+func (v Value) URLE() (*url.URL, error) {
+	raw, err := v.first("url")
+	if err != nil {
+		return nil, err
+	}
+	u, err := parseURL(raw)
+	if err != nil {
+		return nil, v.errorf("url", raw, err)
+	}
+	return u, nil
+}
+
+// URL 转换为*url.URL 失败返回def
+func (v Value) URL(def *url.URL) *url.URL {
+	if u, err := v.URLE(); err == nil {
+		return u
+	}
+	return def
+}
+
+// URLs 把所有值转换为*url.URL
+func (v Value) URLs() ([]*url.URL, error) {
+	out := make([]*url.URL, 0, len(v.Values))
+	err := v.each("url", func(raw string) error {
+		u, err := parseURL(raw)
+		out = append(out, u)
+		return err
+	})
+	return out, err
+}
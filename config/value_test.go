@@ -0,0 +1,126 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustLoad(t *testing.T, s string) *Config {
+	t.Helper()
+	c := New()
+	if err := c.LoadString(s); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestValueGetters(t *testing.T) {
+	c := mustLoad(t, `[server]
+port = 8080
+ratio = 0.75
+debug = yes
+timeout = 30s
+buffer = 4MB
+start = 2024-05-06
+url = http://example.com/api
+big = 18446744073709551615
+`)
+	v := c.Section("server")
+	if n, err := v.Lookup("port").IntE(); err != nil || n != 8080 {
+		t.Errorf("IntE = %d, %v", n, err)
+	}
+	if n, err := v.Lookup("big").UintE(); err != nil || n != 18446744073709551615 {
+		t.Errorf("UintE = %d, %v", n, err)
+	}
+	if f, err := v.Lookup("ratio").FloatE(); err != nil || f != 0.75 {
+		t.Errorf("FloatE = %v, %v", f, err)
+	}
+	if b, err := v.Lookup("debug").BoolE(); err != nil || !b {
+		t.Errorf("BoolE = %v, %v", b, err)
+	}
+	if d, err := v.Lookup("timeout").DurationE(); err != nil || d != 30*time.Second {
+		t.Errorf("DurationE = %v, %v", d, err)
+	}
+	if n, err := v.Lookup("buffer").SizeE(); err != nil || n != 4<<20 {
+		t.Errorf("SizeE = %d, %v", n, err)
+	}
+	if tm, err := v.Lookup("start").TimeE(); err != nil || tm.Day() != 6 {
+		t.Errorf("TimeE = %v, %v", tm, err)
+	}
+	if u, err := v.Lookup("url").URLE(); err != nil || u.Host != "example.com" {
+		t.Errorf("URLE = %v, %v", u, err)
+	}
+	if n := v.Lookup("missing").Int(9); n != 9 {
+		t.Errorf("Int default = %d", n)
+	}
+	if n := v.Lookup("url").Int(9); n != 9 {
+		t.Errorf("Int default on bad value = %d", n)
+	}
+}
+
+func TestValueErrors(t *testing.T) {
+	c := mustLoad(t, `[db]
+port = 80x
+ports = 1
+ports = x
+`)
+	_, err := c.Lookup("db", "port").IntE()
+	if err == nil || err.Error() != `config: [db] port = "80x": invalid int: invalid syntax` {
+		t.Errorf("got %v", err)
+	}
+	_, err = c.Lookup("db", "nope").IntE()
+	if !errors.Is(err, ErrMissing) || !strings.Contains(err.Error(), "[db] nope") {
+		t.Errorf("got %v", err)
+	}
+	_, err = c.Lookup("db", "ports").Ints()
+	if err == nil || !strings.Contains(err.Error(), `[db] ports = "x"`) {
+		t.Errorf("got %v", err)
+	}
+	// 通过ConfigSection获取时同样包含块名
+	_, err = c.GetSection("db").View("db").Lookup("port").IntE()
+	if err == nil || !strings.Contains(err.Error(), `[db] port = "80x"`) {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestValueSlices(t *testing.T) {
+	c := mustLoad(t, `[s]
+n = 1
+n = 2
+d = 1s
+d = 1m
+`)
+	if ns, err := c.Lookup("s", "n").Ints(); err != nil || len(ns) != 2 || ns[1] != 2 {
+		t.Errorf("Ints = %v, %v", ns, err)
+	}
+	if ds, err := c.Lookup("s", "d").Durations(); err != nil || ds[1] != time.Minute {
+		t.Errorf("Durations = %v, %v", ds, err)
+	}
+	if ns, err := c.Lookup("s", "none").Ints(); err != nil || len(ns) != 0 {
+		t.Errorf("missing Ints = %v, %v", ns, err)
+	}
+}
+
+func TestParseBoolAndSize(t *testing.T) {
+	for _, s := range []string{"true", "YES", "on", "1"} {
+		if b, err := ParseBool(s); err != nil || !b {
+			t.Errorf("ParseBool(%q) = %v, %v", s, b, err)
+		}
+	}
+	if _, err := ParseBool("maybe"); err == nil {
+		t.Error("ParseBool(maybe) succeeded")
+	}
+	sizes := map[string]int64{"512": 512, "1k": 1024, "1.5 MB": 3 << 19, "2GiB": 2 << 30, "8388607TB": 8388607 << 40}
+	for s, want := range sizes {
+		if n, err := ParseSize(s); err != nil || n != want {
+			t.Errorf("ParseSize(%q) = %d, %v", s, n, err)
+		}
+	}
+	for _, s := range []string{"", "MB", "10 parsecs", "8388608TB", "9999999TB"} {
+		if _, err := ParseSize(s); err == nil {
+			t.Errorf("ParseSize(%q) succeeded", s)
+		}
+	}
+}
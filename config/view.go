@@ -0,0 +1,181 @@
+package config
+
+import "sort"
+
+// View 某一时刻生效内容的只读视图
+// 加载和Reload整体替换内容 不修改已有的内容 所以读取View不需要加锁
+// 多次读取需要看到同一份内容时 先取得View再读取
+//
+//	v := config.Default().View()
+//	host, port := v.GetValue("db", "host"), v.GetValue("db", "port")
+//
+// 返回的数组和块都是副本 修改它们不影响配置
+type View struct {
+	snap *snapshot
+}
+
+// View 返回当前生效内容的只读视图 没有加载时所有查询返回空
+func (c *Config) View() View {
+	return View{snap: c.snapshot()}
+}
+
+// Section 返回块的只读视图
+func (c *Config) Section(sec string) SectionView {
+	return c.View().Section(sec)
+}
+
+// Sections 按字母顺序返回全部块名
+func (v View) Sections() []string {
+	if v.snap == nil {
+		return []string{}
+	}
+	secs := make([]string, 0, len(v.snap.file))
+	for sec := range v.snap.file {
+		secs = append(secs, sec)
+	}
+	sort.Strings(secs)
+	return secs
+}
+
+// Section 返回块的只读视图
+func (v View) Section(sec string) SectionView {
+	sv := SectionView{name: sec}
+	if v.snap != nil {
+		sv.m = v.snap.file[sec]
+	}
+	return sv
+}
+
+// GetSection 返回块的副本 块不存在时返回nil
+func (v View) GetSection(sec string) ConfigSection {
+	if v.snap == nil {
+		return nil
+	}
+	m, has := v.snap.file[sec]
+	if !has {
+		return nil
+	}
+	return m.clone()
+}
+
+// GetValueSlice 返回key全部值的副本
+func (v View) GetValueSlice(sec, key string) []string {
+	return v.Section(sec).Values(key)
+}
+
+// GetValue 返回key的第一个值 不存在时返回空字符串
+func (v View) GetValue(sec, key string) string {
+	return v.Section(sec).Get(key)
+}
+
+// Lookup 获取[sec]中key对应的值
+func (v View) Lookup(sec, key string) Value {
+	return Value{Section: sec, Key: key, Values: v.Section(sec).valuesOrNil(key)}
+}
+
+// Origin 返回[sec]中key第一个值所在的位置 规则同Config.Origin
+func (v View) Origin(sec, key string) (Origin, bool) {
+	if v.snap == nil {
+		return Origin{}, false
+	}
+	return v.snap.origin(sec, key)
+}
+
+// Origins 返回[sec]中key每个值来源的副本
+func (v View) Origins(sec, key string) []Origin {
+	if v.snap == nil {
+		return nil
+	}
+	list := v.snap.origins[sec][key]
+	if list == nil {
+		return nil
+	}
+	return append([]Origin{}, list...)
+}
+
+// IsSecret key的值是否是加密保存的
+func (v View) IsSecret(sec, key string) bool {
+	return v.snap != nil && v.snap.secrets[sec][key]
+}
+
+// SectionView 一个块的只读视图
+type SectionView struct {
+	name string
+	m    ConfigSection
+}
+
+// Name 块名
+func (s SectionView) Name() string {
+	return s.name
+}
+
+// Exists 块是否存在
+func (s SectionView) Exists() bool {
+	return s.m != nil
+}
+
+// Len key的个数
+func (s SectionView) Len() int {
+	return len(s.m)
+}
+
+// Has key是否存在
+func (s SectionView) Has(key string) bool {
+	_, has := s.m[key]
+	return has
+}
+
+// Keys 按字母顺序返回全部key
+func (s SectionView) Keys() []string {
+	keys := make([]string, 0, len(s.m))
+	for key := range s.m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Get 返回key的第一个值 不存在时返回空字符串
+func (s SectionView) Get(key string) string {
+	if vals := s.m[key]; len(vals) > 0 {
+		return vals[0]
+	}
+	return ""
+}
+
+// Values 返回key全部值的副本 不存在时返回空数组
+func (s SectionView) Values(key string) []string {
+	return append([]string{}, s.m[key]...)
+}
+
+func (s SectionView) valuesOrNil(key string) []string {
+	vals := s.m[key]
+	if vals == nil {
+		return nil
+	}
+	return append([]string{}, vals...)
+}
+
+// Lookup 获取key对应的值
+func (s SectionView) Lookup(key string) Value {
+	return Value{Section: s.name, Key: key, Values: s.valuesOrNil(key)}
+}
+
+// clone 复制块和其中的数组
+func (m ConfigSection) clone() ConfigSection {
+	n := make(ConfigSection, len(m))
+	for key, vals := range m {
+		n[key] = append([]string{}, vals...)
+	}
+	return n
+}
+
+// Current 返回默认配置当前生效内容的只读视图
+func Current() View {
+	return defConfig.View()
+}
+
+// Section 返回默认配置中块的只读视图
+func Section(sec string) SectionView {
+	return defConfig.Section(sec)
+}
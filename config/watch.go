@@ -0,0 +1,320 @@
+package config
+
+import (
+	"crypto/sha256"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ChangeType 配置变化的类型
+type ChangeType int
+
+const (
+	KeyAdded   ChangeType = iota // 新增的key
+	KeyRemoved                   // 删除的key
+	KeyChanged                   // 值发生变化的key
+)
+
+func (t ChangeType) String() string {
+	switch t {
+	case KeyAdded:
+		return "added"
+	case KeyRemoved:
+		return "removed"
+	case KeyChanged:
+		return "changed"
+	default:
+		return "unknown"
+	}
+}
+
+// Change 一个key的变化
+type Change struct {
+	Section string
+	Key     string
+	Type    ChangeType
+	// 变化前后的值 新增时Old为nil 删除时New为nil
+	Old []string
+	New []string
+}
+
+// ErrNotReloadable 当前内容不是从文件加载的 不能重新加载
+var ErrNotReloadable = errors.New("config: not loaded from files, cannot reload")
+
+// OnChange 注册内容变化的回调 每次替换内容后调用 参数按块名和key排序
+// 回调在替换内容的goroutine中执行 不要在回调中阻塞 并发加载时回调的顺序不保证
+func (c *Config) OnChange(fn func([]Change)) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.onChange = append(c.onChange, fn)
+}
+
+// OnError 注册重新加载失败的回调 失败时保留之前的内容
+func (c *Config) OnError(fn func(error)) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.onError = append(c.onError, fn)
+}
+
+// Reload 按最近一次LoadFile或者LoadLayers的方式重新加载
+// 失败时保留之前的内容 并通知OnError的回调
+func (c *Config) Reload() error {
+	// 在publish中读取loader 使用替换时最新的加载方式
+	err := c.publish(func() (*snapshot, error) {
+		c.lock.Lock()
+		loader := c.loader
+		c.lock.Unlock()
+		if loader == nil {
+			return nil, ErrNotReloadable
+		}
+		return loader()
+	}, nil)
+	switch err {
+	case nil, errNotModified:
+		return nil
+	case ErrNotReloadable:
+		return err
+	}
+	c.reportError(err)
+	return err
+}
+
+func (c *Config) reportError(err error) {
+	c.lock.Lock()
+	fns := append([]func(error){}, c.onError...)
+	c.lock.Unlock()
+	for _, fn := range fns {
+		fn(err)
+	}
+}
+
+// notify 计算新旧内容的差异并通知订阅者
+func (c *Config) notify(old, snap *snapshot) {
+	c.lock.Lock()
+	fns := append([]func([]Change){}, c.onChange...)
+	c.lock.Unlock()
+	if len(fns) == 0 {
+		return
+	}
+	changes := diff(old, snap)
+	if len(changes) == 0 {
+		return
+	}
+	for _, fn := range fns {
+		fn(changes)
+	}
+}
+
+// Diff 比较两份配置当前生效的内容 返回从a到b的变化 按块名和key排序
+func Diff(a, b *Config) []Change {
+	olds, news := a.snapshot(), b.snapshot()
+	if olds == nil {
+		olds = newSnapshot()
+	}
+	if news == nil {
+		news = newSnapshot()
+	}
+	return diff(olds, news)
+}
+
+// diff 比较两份内容 只比较值 来源的变化不算变化
+func diff(old, snap *snapshot) []Change {
+	changes := []Change{}
+	for sec, values := range old.file {
+		for key, vals := range values {
+			if nvals, has := snap.file[sec][key]; !has {
+				changes = append(changes, Change{Section: sec, Key: key, Type: KeyRemoved, Old: copyStrings(vals)})
+			} else if !equalStrings(vals, nvals) {
+				changes = append(changes, Change{Section: sec, Key: key, Type: KeyChanged, Old: copyStrings(vals), New: copyStrings(nvals)})
+			}
+		}
+	}
+	for sec, values := range snap.file {
+		for key, vals := range values {
+			if _, has := old.file[sec][key]; !has {
+				changes = append(changes, Change{Section: sec, Key: key, Type: KeyAdded, New: copyStrings(vals)})
+			}
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Section != changes[j].Section {
+			return changes[i].Section < changes[j].Section
+		}
+		return changes[i].Key < changes[j].Key
+	})
+	return changes
+}
+
+func copyStrings(list []string) []string {
+	return append([]string{}, list...)
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// fileState 文件的状态 修改时间或大小变化时再比较内容的hash
+type fileState struct {
+	exist   bool
+	modtime time.Time
+	size    int64
+	sum     [sha256.Size]byte
+}
+
+func statFile(name string, prev *fileState) fileState {
+	info, err := os.Stat(name)
+	if err != nil {
+		return fileState{}
+	}
+	st := fileState{exist: true, modtime: info.ModTime(), size: info.Size()}
+	if prev != nil && prev.exist && prev.modtime.Equal(st.modtime) && prev.size == st.size {
+		st.sum = prev.sum
+		return st
+	}
+	if data, err := ioutil.ReadFile(name); err == nil {
+		st.sum = sha256.Sum256(data)
+	}
+	return st
+}
+
+// Watcher 轮询配置文件 内容变化时重新加载
+// 不依赖文件系统通知 只比较修改时间 大小和内容hash
+type Watcher struct {
+	c        *Config
+	interval time.Duration
+	files    map[string]fileState
+	globs    map[string][]string
+	// 每次都调用Reload 用于远程配置 由Reload自己判断是否变化
+	always bool
+	stop   chan struct{}
+	once   sync.Once
+	done   chan struct{}
+}
+
+// Watch 每隔interval检查一次加载过的文件 包括include的文件
+// 内容变化时调用Reload 失败时保留之前的内容并通知OnError
+func (c *Config) Watch(interval time.Duration) *Watcher {
+	return c.watch(interval, false)
+}
+
+func (c *Config) watch(interval time.Duration, always bool) *Watcher {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	w := &Watcher{
+		c:        c,
+		interval: interval,
+		always:   always,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	w.snapshotFiles(c.snapshot())
+	go w.run()
+	return w
+}
+
+// Stop 停止轮询 可以重复调用
+func (w *Watcher) Stop() {
+	w.once.Do(func() { close(w.stop) })
+	<-w.done
+}
+
+func (w *Watcher) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			w.check()
+		}
+	}
+}
+
+// snapshotFiles 记录snap读取过的文件的当前状态
+func (w *Watcher) snapshotFiles(snap *snapshot) {
+	files := make(map[string]fileState)
+	globs := make(map[string][]string)
+	if snap != nil {
+		for _, name := range snap.files {
+			prev, ok := w.files[name]
+			if ok {
+				files[name] = statFile(name, &prev)
+			} else {
+				files[name] = statFile(name, nil)
+			}
+		}
+		for pattern, names := range snap.globs {
+			globs[pattern] = names
+		}
+	}
+	w.files, w.globs = files, globs
+}
+
+// changed 判断是否有文件变化 include_glob匹配到的文件列表变化也算
+func (w *Watcher) changed() bool {
+	changed := false
+	for name, prev := range w.files {
+		p := prev
+		st := statFile(name, &p)
+		if st.exist != prev.exist || st.sum != prev.sum {
+			changed = true
+		}
+		w.files[name] = st
+	}
+	for pattern, names := range w.globs {
+		matches, _ := filepath.Glob(pattern)
+		sort.Strings(matches)
+		if !equalStrings(matches, names) {
+			changed = true
+			w.globs[pattern] = matches
+		}
+	}
+	return changed
+}
+
+func (w *Watcher) check() {
+	if !w.always && !w.changed() {
+		return
+	}
+	// 失败时文件状态已经更新 修复之前不会重复报告同一个错误
+	if err := w.c.Reload(); err != nil {
+		return
+	}
+	w.snapshotFiles(w.c.snapshot())
+}
+
+// Watch 轮询默认配置的文件
+func Watch(interval time.Duration) *Watcher {
+	return defConfig.Watch(interval)
+}
+
+// OnChange 注册默认配置内容变化的回调
+func OnChange(fn func([]Change)) {
+	defConfig.OnChange(fn)
+}
+
+// OnError 注册默认配置重新加载失败的回调
+func OnError(fn func(error)) {
+	defConfig.OnError(fn)
+}
+
+// Reload 重新加载默认配置
+func Reload() error {
+	return defConfig.Reload()
+}
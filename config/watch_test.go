@@ -0,0 +1,189 @@
+package config
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	a := mustLoad(t, "[db]\nhost = a\nport = 1\nuser = root\n[old]\nk = v\n")
+	b := mustLoad(t, "[db]\nhost = b\nport = 1\npass = x\n[new]\nk = v\n")
+	want := []Change{
+		{Section: "db", Key: "host", Type: KeyChanged, Old: []string{"a"}, New: []string{"b"}},
+		{Section: "db", Key: "pass", Type: KeyAdded, New: []string{"x"}},
+		{Section: "db", Key: "user", Type: KeyRemoved, Old: []string{"root"}},
+		{Section: "new", Key: "k", Type: KeyAdded, New: []string{"v"}},
+		{Section: "old", Key: "k", Type: KeyRemoved, Old: []string{"v"}},
+	}
+	if got := Diff(a, b); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v", got)
+	}
+	if got := Diff(a, a); len(got) != 0 {
+		t.Errorf("same config: %+v", got)
+	}
+	// 没有加载的配置按空配置比较
+	if got := Diff(New(), b); len(got) != 4 || got[0].Type != KeyAdded {
+		t.Errorf("from empty: %+v", got)
+	}
+	if KeyRemoved.String() != "removed" || ChangeType(9).String() != "unknown" {
+		t.Error("ChangeType.String")
+	}
+}
+
+func TestReloadKeepsLastGood(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"app.ini": "[db]\nhost = a\nuser = root\n"})
+	name := filepath.Join(dir, "app.ini")
+	c := New()
+	c.SetSchema(&Schema{Sections: []SectionSchema{{
+		Name:         "db",
+		AllowUnknown: true,
+		Keys:         []KeySchema{{Name: "port", Type: TypeInt}},
+	}}})
+	var errs []error
+	var changes [][]Change
+	c.OnError(func(err error) { errs = append(errs, err) })
+	c.OnChange(func(list []Change) { changes = append(changes, list) })
+	if err := c.LoadFile(name); err != nil {
+		t.Fatal(err)
+	}
+
+	// 文件不存在和不符合声明时都保留之前的内容
+	os.Remove(name)
+	if err := c.Reload(); err == nil {
+		t.Error("reload of a missing file succeeded")
+	}
+	ioutil.WriteFile(name, []byte("[db]\nhost = b\nport = x\n"), 0644)
+	if err := c.Reload(); !errors.As(err, new(ValidationErrors)) {
+		t.Errorf("got %v", err)
+	}
+	if len(errs) != 2 || len(changes) != 0 || c.GetValue("db", "host") != "a" {
+		t.Fatalf("errors %v, changes %v, host %q", errs, changes, c.GetValue("db", "host"))
+	}
+
+	ioutil.WriteFile(name, []byte("[db]\nhost = b\nport = 1\n"), 0644)
+	if err := c.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	want := []Change{
+		{Section: "db", Key: "host", Type: KeyChanged, Old: []string{"a"}, New: []string{"b"}},
+		{Section: "db", Key: "port", Type: KeyAdded, New: []string{"1"}},
+		{Section: "db", Key: "user", Type: KeyRemoved, Old: []string{"root"}},
+	}
+	if len(changes) != 1 || !reflect.DeepEqual(changes[0], want) {
+		t.Errorf("changes %+v", changes)
+	}
+	// 内容没有变化时不通知
+	if err := c.Reload(); err != nil || len(changes) != 1 {
+		t.Errorf("unchanged reload: %v, %d changes", err, len(changes))
+	}
+
+	if err := mustLoad(t, "[a]\nk = v\n").Reload(); err != ErrNotReloadable {
+		t.Errorf("LoadString: %v", err)
+	}
+	if len(errs) != 2 {
+		t.Errorf("ErrNotReloadable reported: %v", errs)
+	}
+}
+
+func TestReloadOrder(t *testing.T) {
+	c := New()
+	var calls int32
+	entered, release := make(chan struct{}), make(chan struct{})
+	err := c.loadWith(func() (*snapshot, error) {
+		n := atomic.AddInt32(&calls, 1)
+		if n == 2 {
+			// 第一次Reload读取的较慢
+			close(entered)
+			<-release
+		}
+		return parse(strings.NewReader("[a]\nv = "+strconv.Itoa(int(n))+"\n"), "", c.parseOptions())
+	}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		c.Reload()
+	}()
+	<-entered
+	go func() {
+		defer wg.Done()
+		c.Reload()
+	}()
+	// 第二次Reload要等第一次替换之后才能读取
+	time.Sleep(50 * time.Millisecond)
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("second reload read while the first was in progress: %d calls", n)
+	}
+	close(release)
+	wg.Wait()
+	if got := c.GetValue("a", "v"); got != "3" {
+		t.Errorf("older read won: v = %s", got)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"app.ini":    "include = common.ini\n[db]\nhost = a\n",
+		"common.ini": "[db]\nport = 1\n",
+	})
+	c := New()
+	changed := make(chan []Change, 10)
+	failed := make(chan error, 10)
+	c.OnChange(func(list []Change) { changed <- list })
+	c.OnError(func(err error) { failed <- err })
+	if err := c.LoadFile(filepath.Join(dir, "app.ini")); err != nil {
+		t.Fatal(err)
+	}
+	w := c.Watch(10 * time.Millisecond)
+	defer w.Stop()
+	wait := func(what string) {
+		t.Helper()
+		select {
+		case list := <-changed:
+			t.Logf("%s: %+v", what, list)
+		case err := <-failed:
+			t.Fatalf("%s: %v", what, err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: no reload", what)
+		}
+	}
+
+	// include的文件也会检查
+	ioutil.WriteFile(filepath.Join(dir, "common.ini"), []byte("[db]\nport = 22\n"), 0644)
+	wait("include")
+	if c.GetValue("db", "port") != "22" {
+		t.Fatalf("port = %q", c.GetValue("db", "port"))
+	}
+
+	// 失败时只报告一次 保留之前的内容
+	ioutil.WriteFile(filepath.Join(dir, "app.ini"), []byte("include = none.ini\n"), 0644)
+	select {
+	case <-failed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no error reported")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if len(failed) != 0 || c.GetValue("db", "host") != "a" {
+		t.Fatalf("repeated errors %d, host %q", len(failed), c.GetValue("db", "host"))
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, "app.ini"), []byte("include = common.ini\n[db]\nhost = bb\n"), 0644)
+	wait("fixed")
+	if c.GetValue("db", "host") != "bb" {
+		t.Fatalf("host = %q", c.GetValue("db", "host"))
+	}
+	w.Stop()
+	w.Stop()
+}
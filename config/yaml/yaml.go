@@ -0,0 +1,54 @@
+// Package yaml 注册.yaml和.yml格式的配置文件
+//
+//	import _ "github.com/go-irain/tools/config/yaml"
+//
+// 导入后config.LoadFile按扩展名解析yaml文件
+// 顶层的对象对应块 嵌套的对象使用.连接的块名
+package yaml
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/go-irain/tools/config"
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	config.RegisterFormat(".yaml", decode)
+	config.RegisterFormat(".yml", decode)
+}
+
+func decode(r io.Reader) (map[string]interface{}, error) {
+	var tree map[string]interface{}
+	if err := yaml.NewDecoder(r).Decode(&tree); err != nil && err != io.EOF {
+		return nil, err
+	}
+	if tree == nil {
+		tree = make(map[string]interface{})
+	}
+	return normalize(tree).(map[string]interface{}), nil
+}
+
+// normalize 把key不是字符串的对象转换为map[string]interface{}
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, e := range v {
+			v[key] = normalize(e)
+		}
+		return v
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, e := range v {
+			m[fmt.Sprint(key)] = normalize(e)
+		}
+		return m
+	case []interface{}:
+		for i, e := range v {
+			v[i] = normalize(e)
+		}
+		return v
+	}
+	return v
+}
@@ -0,0 +1,139 @@
+package log
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CallerFormat 行号信息的显示方式
+type CallerFormat uint32
+
+const (
+	C_SHORT CallerFormat = iota // 文件名去掉.go后缀 main:36
+	C_FULL                      // 完整路径 /src/app/main.go:36
+	C_FUNC                      // 包名和函数名 main.main:36
+)
+
+// 查找调用方时最多向上查找的层数 只有注册了Helper时才需要
+const maxCallerFrames = 16
+
+// pc到栈帧的缓存 同一个调用位置只解析一次
+// 内联的函数一个pc会对应多个栈帧
+var frameCache sync.Map // map[uintptr][]runtime.Frame
+
+func cachedFrames(pc uintptr) []runtime.Frame {
+	if v, ok := frameCache.Load(pc); ok {
+		return v.([]runtime.Frame)
+	}
+	frames := []runtime.Frame{}
+	it := runtime.CallersFrames([]uintptr{pc})
+	for {
+		frame, more := it.Next()
+		frames = append(frames, frame)
+		if !more {
+			break
+		}
+	}
+	frameCache.Store(pc, frames)
+	return frames
+}
+
+// Helper 把调用Helper的函数标记为日志辅助函数
+// 和testing.T.Helper类似 显示行号时会跳过这些函数 显示调用它们的位置
+func (log *Log) Helper() {
+	var pcs [1]uintptr
+	if runtime.Callers(2, pcs[:]) == 0 {
+		return
+	}
+	log.markHelper(pcs[0])
+}
+
+func (log *Log) markHelper(pc uintptr) {
+	name := cachedFrames(pc)[0].Function
+	if _, ok := log.helpers.Load(name); !ok {
+		log.helpers.Store(name, struct{}{})
+		log.lock.Lock()
+		log.hasHelper = true
+		log.storeOptions()
+		log.lock.Unlock()
+	}
+}
+
+// AddCallerSkip 在Output的calldepth基础上额外跳过n层调用
+// 用于把log包装在自己的函数里 n可以为负数 累加后小于0按0处理
+func (log *Log) AddCallerSkip(n int) {
+	log.lock.Lock()
+	defer log.lock.Unlock()
+	log.callerskip += n
+	if log.callerskip < 0 {
+		log.callerskip = 0
+	}
+	log.storeOptions()
+}
+
+// SetCallerFormat 设置行号信息的显示方式
+func (log *Log) SetCallerFormat(format CallerFormat) {
+	log.lock.Lock()
+	defer log.lock.Unlock()
+	if format > C_FUNC {
+		format = C_SHORT
+	}
+	log.callerformat = format
+	log.storeOptions()
+}
+
+// appendCaller 追加Output调用方的位置 格式由format决定
+// skip和runtime.Caller相同 0表示appendCaller的调用方
+func (log *Log) appendCaller(b []byte, skip int, format CallerFormat, hasHelper bool) []byte {
+	var pcs [maxCallerFrames]uintptr
+	depth := 1
+	if hasHelper {
+		depth = maxCallerFrames
+	}
+	// +2 跳过runtime.Callers和appendCaller自己
+	n := runtime.Callers(skip+2, pcs[:depth])
+	if n == 0 {
+		return appendFrame(b, format, runtime.Frame{})
+	}
+	// 从内向外找到第一个不是Helper的栈帧 全部是Helper时使用最外层
+	var found runtime.Frame
+	for i := 0; i < n; i++ {
+		for _, frame := range cachedFrames(pcs[i]) {
+			found = frame
+			if !hasHelper {
+				return appendFrame(b, format, frame)
+			}
+			if _, ok := log.helpers.Load(frame.Function); !ok {
+				return appendFrame(b, format, frame)
+			}
+		}
+	}
+	return appendFrame(b, format, found)
+}
+
+func appendFrame(b []byte, format CallerFormat, frame runtime.Frame) []byte {
+	var name string
+	switch format {
+	case C_FULL:
+		name = frame.File
+	case C_FUNC:
+		name = frame.Function
+		if i := strings.LastIndexByte(name, '/'); i >= 0 {
+			name = name[i+1:]
+		}
+	default:
+		name = frame.File
+		if i := strings.LastIndexByte(name, '/'); i >= 0 {
+			name = name[i+1:]
+		}
+		name = strings.TrimSuffix(name, ".go")
+	}
+	if name == "" {
+		name = "???"
+	}
+	b = append(b, name...)
+	b = append(b, ':')
+	return strconv.AppendInt(b, int64(frame.Line), 10)
+}
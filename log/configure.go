@@ -0,0 +1,337 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-irain/tools/config"
+)
+
+// 配置块示例
+//
+//	[log]
+//	level = info
+//	showline = false
+//	timeformat = rfc3339ms
+//	timezone = UTC
+//	dir = /var/log/app
+//	maxsize = 100
+//	maxcount = 10
+//	tag = audit
+//	tag = request
+//
+//	[log.tag.audit]
+//	level = debug
+//	maxsize = 500
+//	maxcount = 30
+//	encrypt_key = secret
+
+// 日志配置块支持的key
+var configKeys = map[string]bool{
+	"level": true, "showline": true, "dir": true, "maxsize": true, "maxcount": true, "tag": true,
+	"timeformat": true, "timezone": true,
+}
+
+// tag配置块支持的key
+var tagConfigKeys = map[string]bool{
+	"level": true, "maxsize": true, "maxcount": true, "encrypt_key": true,
+}
+
+// 配置了dir但没有设置大小和数量时使用的默认值
+const (
+	defaultConfigMaxsize  = 100
+	defaultConfigMaxcount = 10
+)
+
+// checkConfigKeys 检查未知的key 返回的错误包含key的名字
+func checkConfigKeys(name string, sec config.ConfigSection, known map[string]bool) error {
+	keys := make([]string, 0, len(sec))
+	for key := range sec {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !known[key] {
+			return fmt.Errorf("log config %s: unknown key %q", name, key)
+		}
+	}
+	return nil
+}
+
+// configInt 读取正整数配置 不存在返回0
+func configInt(name string, sec config.ConfigSection, key string) (int, error) {
+	val := sec.GetValue(key)
+	if val == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(val)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("log config %s: invalid %s=%q, need a positive integer", name, key, val)
+	}
+	return n, nil
+}
+
+// Configure 根据配置块设置日志等级 行号 输出目录和标签
+// 先校验全部的key和目录 有错误时不修改任何设置
+// 重复调用时dir和之前相同只更新文件大小和数量 不同时切换到新的目录
+func (log *Log) Configure(sec config.ConfigSection) error {
+	conf, err := log.parseConfig("section", sec)
+	if err != nil {
+		return err
+	}
+	log.applyConfig(conf)
+	return nil
+}
+
+// logConfig 校验过的配置块 应用时不会再失败
+type logConfig struct {
+	level      Level
+	hasLevel   bool
+	showline   bool
+	hasShow    bool
+	timeformat string
+	loc        *time.Location
+	hasLoc     bool
+	// 需要注册的新tag
+	tags []string
+	// 输出目录的绝对路径 为空表示不修改
+	dir      string
+	sameDir  bool
+	maxsize  int
+	maxcount int
+}
+
+func (log *Log) parseConfig(name string, sec config.ConfigSection) (*logConfig, error) {
+	if err := checkConfigKeys(name, sec, configKeys); err != nil {
+		return nil, err
+	}
+	conf := &logConfig{}
+	if val := sec.GetValue("level"); val != "" {
+		l, err := ParseLevel(val)
+		if err != nil {
+			return nil, fmt.Errorf("log config %s: invalid level=%q", name, val)
+		}
+		conf.level, conf.hasLevel = l, true
+	}
+	if val := sec.GetValue("showline"); val != "" {
+		b, err := config.ParseBool(val)
+		if err != nil {
+			return nil, fmt.Errorf("log config %s: invalid showline=%q, %v", name, val, err)
+		}
+		conf.showline, conf.hasShow = b, true
+	}
+	if val := sec.GetValue("timeformat"); val != "" {
+		conf.timeformat = ParseTimeFormat(val)
+	}
+	if val := sec.GetValue("timezone"); val != "" {
+		loc, err := ParseTimeLocation(val)
+		if err != nil {
+			return nil, fmt.Errorf("log config %s: invalid timezone=%q", name, val)
+		}
+		conf.loc, conf.hasLoc = loc, true
+	}
+	var err error
+	if conf.maxsize, err = configInt(name, sec, "maxsize"); err != nil {
+		return nil, err
+	}
+	if conf.maxcount, err = configInt(name, sec, "maxcount"); err != nil {
+		return nil, err
+	}
+	dir := sec.GetValue("dir")
+	if dir == "" && (conf.maxsize > 0 || conf.maxcount > 0) {
+		return nil, fmt.Errorf("log config %s: maxsize and maxcount need dir", name)
+	}
+	if conf.maxsize == 0 {
+		conf.maxsize = defaultConfigMaxsize
+	}
+	if conf.maxcount == 0 {
+		conf.maxcount = defaultConfigMaxcount
+	}
+
+	log.lock.Lock()
+	defer log.lock.Unlock()
+	// SetTags遇到空的名字和已经存在的tag会失败 先检查 只注册新的tag
+	seen := make(map[string]bool)
+	for _, tag := range sec.GetValueSlice("tag") {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			return nil, fmt.Errorf("log config %s: invalid tag, name is empty", name)
+		}
+		if _, exist := log.tags[tag]; !exist && !seen[tag] {
+			conf.tags = append(conf.tags, tag)
+		}
+		seen[tag] = true
+	}
+	// 目录和当前相同时只修改大小和数量 重新加载配置时不会重复设置目录
+	if dir != "" {
+		abs, err := filepath.Abs(dir)
+		if err == nil {
+			conf.dir = abs
+			if log.path == filepath.Clean(abs)+string(filepath.Separator) {
+				conf.sameDir = true
+			} else {
+				err = checkOutDir(abs)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("log config %s: invalid dir=%q: %v", name, dir, err)
+		}
+	}
+	return conf, nil
+}
+
+func (log *Log) applyConfig(conf *logConfig) {
+	if conf.hasLevel {
+		log.SetLevel(conf.level)
+	}
+	if conf.hasShow {
+		log.SetShowLineNumber(conf.showline)
+	}
+	if conf.timeformat != "" {
+		log.SetTimeFormat(conf.timeformat)
+	}
+	if conf.hasLoc {
+		log.SetTimeLocation(conf.loc)
+	}
+	log.lock.Lock()
+	defer log.lock.Unlock()
+	for _, tag := range conf.tags {
+		if _, exist := log.tags[tag]; exist {
+			continue
+		}
+		if log.path == "" {
+			log.tags[tag] = os.Stdout
+		} else {
+			log.tags[tag] = log.newTagWriteIO(tag)
+		}
+	}
+	switch {
+	case conf.dir == "":
+	case !conf.sameDir:
+		log.setOutDir(conf.dir, conf.maxsize, conf.maxcount)
+	case log.maxsize != int64(conf.maxsize)*MB || log.maxcoutnum != conf.maxcount:
+		log.setOutSize(conf.maxsize, conf.maxcount)
+	}
+}
+
+// ConfigureTag 根据配置块设置tag单独的等级 文件大小数量和加密密钥
+// tag需要已经通过SetTags或者配置中的tag注册 有错误时不修改任何设置
+func (log *Log) ConfigureTag(tag string, sec config.ConfigSection) error {
+	name := "tag " + tag
+	conf, err := parseTagConfig(name, tag, sec)
+	if err != nil {
+		return err
+	}
+	log.lock.Lock()
+	_, exist := log.tags[tag]
+	log.lock.Unlock()
+	if !exist {
+		return fmt.Errorf("log config %s: tag is not exist", name)
+	}
+	return log.applyTagConfig(name, conf)
+}
+
+// tagConfigSection 校验过的tag配置块
+type tagConfigSection struct {
+	tag      string
+	level    Level
+	hasLevel bool
+	maxsize  int
+	maxcount int
+	key      []byte
+}
+
+func parseTagConfig(name, tag string, sec config.ConfigSection) (*tagConfigSection, error) {
+	if err := checkConfigKeys(name, sec, tagConfigKeys); err != nil {
+		return nil, err
+	}
+	conf := &tagConfigSection{tag: tag}
+	if val := sec.GetValue("level"); val != "" {
+		l, err := ParseLevel(val)
+		if err != nil {
+			return nil, fmt.Errorf("log config %s: invalid level=%q", name, val)
+		}
+		conf.level, conf.hasLevel = l, true
+	}
+	var err error
+	if conf.maxsize, err = configInt(name, sec, "maxsize"); err != nil {
+		return nil, err
+	}
+	if conf.maxcount, err = configInt(name, sec, "maxcount"); err != nil {
+		return nil, err
+	}
+	if key := sec.GetValue("encrypt_key"); key != "" {
+		conf.key = []byte(key)
+	}
+	return conf, nil
+}
+
+// applyTagConfig 应用tag配置 tag已经注册时不会失败
+func (log *Log) applyTagConfig(name string, conf *tagConfigSection) error {
+	if conf.hasLevel {
+		if err := log.SetTagLevel(conf.tag, conf.level); err != nil {
+			return fmt.Errorf("log config %s: %v", name, err)
+		}
+	}
+	if conf.maxsize > 0 || conf.maxcount > 0 {
+		if err := log.SetTagOutConfig(conf.tag, conf.maxsize, conf.maxcount); err != nil {
+			return fmt.Errorf("log config %s: %v", name, err)
+		}
+	}
+	if conf.key != nil {
+		if err := log.SetTagEncryptKey(conf.tag, conf.key); err != nil {
+			return fmt.Errorf("log config %s: invalid encrypt_key: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// ConfigureSection 使用config包默认配置 见ConfigureFrom
+func (log *Log) ConfigureSection(name string) error {
+	return log.ConfigureFrom(config.Default(), name)
+}
+
+// ConfigureFrom 读取c中的[name]块 以及每个tag对应的[name.tag.TAG]块
+// 全部的块都校验通过后才修改设置
+func (log *Log) ConfigureFrom(c *config.Config, name string) error {
+	view := c.View()
+	sec := view.GetSection(name)
+	if sec == nil {
+		return fmt.Errorf("log config: section [%s] not found", name)
+	}
+	conf, err := log.parseConfig("["+name+"]", sec)
+	if err != nil {
+		return err
+	}
+	// 已经注册的tag和配置中新注册的tag
+	log.lock.Lock()
+	tags := append([]string{}, conf.tags...)
+	for tag := range log.tags {
+		tags = append(tags, tag)
+	}
+	log.lock.Unlock()
+	sort.Strings(tags)
+	var tagconfs []*tagConfigSection
+	for _, tag := range tags {
+		tname := "[" + name + ".tag." + tag + "]"
+		if tsec := view.GetSection(name + ".tag." + tag); tsec != nil {
+			tconf, err := parseTagConfig(tname, tag, tsec)
+			if err != nil {
+				return err
+			}
+			tagconfs = append(tagconfs, tconf)
+		}
+	}
+	log.applyConfig(conf)
+	for _, tconf := range tagconfs {
+		if err := log.applyTagConfig("["+name+".tag."+tconf.tag+"]", tconf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
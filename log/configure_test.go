@@ -0,0 +1,177 @@
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-irain/tools/config"
+)
+
+func tempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "logconf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func TestConfigure(t *testing.T) {
+	log := NewLog()
+	err := log.Configure(config.ConfigSection{
+		"level":    {"warn"},
+		"showline": {"off"},
+		"tag":      {"audit", "request", "audit"},
+		"timezone": {"UTC"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if log.Enabled(L_INFO) || !log.Enabled(L_WARN) || log.showFileline {
+		t.Fatal("level or showline not applied")
+	}
+	for _, tag := range []string{"audit", "request"} {
+		if _, ok := log.tags[tag]; !ok {
+			t.Fatalf("tag %s not registered", tag)
+		}
+	}
+	// 重复配置已经存在的tag不是错误
+	if err := log.Configure(config.ConfigSection{"tag": {"audit"}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConfigureErrorsChangeNothing(t *testing.T) {
+	tests := []struct {
+		sec  config.ConfigSection
+		want string
+	}{
+		{config.ConfigSection{"level": {"error"}, "levle": {"x"}}, `unknown key "levle"`},
+		{config.ConfigSection{"level": {"error"}, "tag": {"ok", " "}}, "tag, name is empty"},
+		{config.ConfigSection{"level": {"error"}, "maxsize": {"-1"}, "dir": {"/tmp"}}, "invalid maxsize"},
+		{config.ConfigSection{"level": {"error"}, "maxcount": {"3"}}, "need dir"},
+		{config.ConfigSection{"level": {"error"}, "timezone": {"Mars/Base"}}, "invalid timezone"},
+		{config.ConfigSection{"level": {"error"}, "dir": {"/dev/null/x"}}, "invalid dir"},
+	}
+	for _, tt := range tests {
+		log := NewLog()
+		err := log.Configure(tt.sec)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%v: got %v, want %q", tt.sec, err, tt.want)
+			continue
+		}
+		if !log.Enabled(L_DEBUG) {
+			t.Errorf("%v: level changed although configure failed", tt.sec)
+		}
+		if _, ok := log.tags["ok"]; ok {
+			t.Errorf("%v: tag registered although configure failed", tt.sec)
+		}
+	}
+}
+
+func TestConfigureSameDirTwice(t *testing.T) {
+	dir := tempDir(t)
+	log := NewLog()
+	sec := config.ConfigSection{"dir": {dir}, "maxsize": {"5"}}
+	if err := log.Configure(sec); err != nil {
+		t.Fatal(err)
+	}
+	if log.path != filepath.Clean(dir)+string(filepath.Separator) {
+		t.Fatalf("path %q", log.path)
+	}
+	// 重新加载配置 目录相同只修改大小
+	sec["maxsize"] = []string{"7"}
+	if err := log.Configure(sec); err != nil {
+		t.Fatal(err)
+	}
+	if log.maxsize != 7*MB {
+		t.Fatalf("maxsize %d", log.maxsize)
+	}
+	if _, ok := log.tags[log.defaultTagName].(*WriteIO); !ok {
+		t.Fatal("default tag does not write to file")
+	}
+
+	// 其他Log已经使用的目录返回错误 而不是退出
+	other := NewLog()
+	if err := other.Configure(config.ConfigSection{"dir": {dir}}); err == nil || !strings.Contains(err.Error(), "exist") {
+		t.Fatalf("got %v", err)
+	}
+}
+
+func TestConfigureFrom(t *testing.T) {
+	c := config.New()
+	err := c.LoadString(`[log]
+level = info
+tag = audit
+[log.tag.audit]
+level = error
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := NewLog()
+	if err := log.ConfigureFrom(c, "log"); err != nil {
+		t.Fatal(err)
+	}
+	if log.TagEnabled("audit", L_WARN) || !log.TagEnabled("audit", L_ERROR) || log.Enabled(L_DEBUG) {
+		t.Fatal("tag level not applied")
+	}
+
+	// tag块有错误时[log]块的设置也不生效
+	c.LoadString(`[log]
+level = error
+tag = billing
+[log.tag.billing]
+maxsize = lots
+`)
+	err = log.ConfigureFrom(c, "log")
+	if err == nil || !strings.Contains(err.Error(), "[log.tag.billing]") {
+		t.Fatalf("got %v", err)
+	}
+	if !log.Enabled(L_INFO) {
+		t.Fatal("level changed although tag section is invalid")
+	}
+	if _, ok := log.tags["billing"]; ok {
+		t.Fatal("tag registered although tag section is invalid")
+	}
+}
+
+func TestConfigureSameKeyNoRotate(t *testing.T) {
+	dir := tempDir(t)
+	c := config.New()
+	err := c.LoadString("[log]\ndir = " + dir + "\ntag = audit\n[log.tag.audit]\nencrypt_key = k1\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := NewLog()
+	if err := log.ConfigureFrom(c, "log"); err != nil {
+		t.Fatal(err)
+	}
+	log.TagError("audit", "first")
+	files := func() []string {
+		list, _ := filepath.Glob(filepath.Join(dir, "*"))
+		return list
+	}
+	before := files()
+	// 重新加载相同的密钥不切分文件
+	for i := 0; i < 3; i++ {
+		if err := log.ConfigureFrom(c, "log"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if after := files(); strings.Join(after, ",") != strings.Join(before, ",") {
+		t.Fatalf("files changed: %v -> %v", before, after)
+	}
+	// 密钥变化时切分
+	c.LoadString("[log]\ndir = " + dir + "\ntag = audit\n[log.tag.audit]\nencrypt_key = k2\n")
+	if err := log.ConfigureFrom(c, "log"); err != nil {
+		t.Fatal(err)
+	}
+	if after := files(); len(after) != len(before)+1 {
+		t.Fatalf("key change did not rotate: %v -> %v", before, after)
+	}
+}
@@ -0,0 +1,147 @@
+package log
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/go-irain/tools/aes"
+)
+
+// 加密日志文件格式
+// 文件头: cryptMagic
+// 之后每一行日志为一帧: 4字节长度(大端) + 密文 + 32字节hmac-sha256
+// 密文 = aes.AesEncrypt(16字节随机数 + 明文)
+// 随机数保证相同的日志内容得到不同的密文
+// hmac 覆盖长度和密文 任何一帧被篡改都会校验失败
+// 写到一半的文件 最后一帧不完整时 前面的完整帧仍然可以解密
+const cryptMagic = "ILOGENC1"
+
+const (
+	cryptNonceSize = 16
+	cryptBlockSize = 16
+	cryptMacSize   = sha256.Size
+	// 单帧最大长度 防止损坏的长度字段导致分配过大的内存
+	cryptMaxFrame = 64 * MB
+)
+
+var (
+	// ErrCryptHeader 文件头不是加密日志格式
+	ErrCryptHeader = errors.New("log crypt: invalid file header")
+	// ErrCryptAuth 帧校验失败 密钥错误或者内容被篡改
+	ErrCryptAuth = errors.New("log crypt: frame authentication failed")
+	// ErrCryptTruncated 文件末尾存在不完整的帧
+	ErrCryptTruncated = errors.New("log crypt: truncated frame at end of file")
+)
+
+// hmac使用的密钥和aes的密钥分开派生
+func cryptMacKey(key []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte("log-hmac:"))
+	h.Write(key)
+	return h.Sum(nil)
+}
+
+// encodeCryptFrame 把一行日志编码成一个加密帧
+func encodeCryptFrame(data, key, mackey []byte) ([]byte, error) {
+	plain := make([]byte, cryptNonceSize+len(data))
+	if _, erro := io.ReadFull(rand.Reader, plain[:cryptNonceSize]); erro != nil {
+		return nil, erro
+	}
+	copy(plain[cryptNonceSize:], data)
+	crypted, erro := aes.AesEncrypt(plain, key)
+	if erro != nil {
+		return nil, erro
+	}
+	frame := make([]byte, 4, 4+len(crypted)+cryptMacSize)
+	binary.BigEndian.PutUint32(frame, uint32(len(crypted)))
+	frame = append(frame, crypted...)
+	mac := hmac.New(sha256.New, mackey)
+	mac.Write(frame)
+	return mac.Sum(frame), nil
+}
+
+// CryptReader 读取加密的日志文件 输出明文
+type CryptReader struct {
+	r      *bufio.Reader
+	key    []byte
+	mackey []byte
+	header bool
+	// 当前帧解密后未读取完的数据
+	pending []byte
+}
+
+// NewCryptReader 创建解密读取器 key需要和写入时设置的一致
+func NewCryptReader(r io.Reader, key []byte) *CryptReader {
+	return &CryptReader{
+		r:      bufio.NewReader(r),
+		key:    key,
+		mackey: cryptMacKey(key),
+	}
+}
+
+// ReadFrame 读取并解密下一帧
+// 文件正常结束返回io.EOF 末尾帧不完整返回ErrCryptTruncated
+func (c *CryptReader) ReadFrame() ([]byte, error) {
+	if !c.header {
+		magic := make([]byte, len(cryptMagic))
+		if n, erro := io.ReadFull(c.r, magic); erro != nil {
+			if erro == io.EOF {
+				return nil, io.EOF
+			}
+			if n > 0 && string(magic[:n]) == cryptMagic[:n] {
+				return nil, ErrCryptTruncated
+			}
+			return nil, ErrCryptHeader
+		}
+		if string(magic) != cryptMagic {
+			return nil, ErrCryptHeader
+		}
+		c.header = true
+	}
+	head := make([]byte, 4)
+	if _, erro := io.ReadFull(c.r, head); erro != nil {
+		if erro == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, ErrCryptTruncated
+	}
+	size := binary.BigEndian.Uint32(head)
+	if int64(size) > cryptMaxFrame || size == 0 || size%cryptBlockSize != 0 {
+		return nil, ErrCryptAuth
+	}
+	body := make([]byte, int(size)+cryptMacSize)
+	if _, erro := io.ReadFull(c.r, body); erro != nil {
+		return nil, ErrCryptTruncated
+	}
+	crypted, sum := body[:size], body[size:]
+	mac := hmac.New(sha256.New, c.mackey)
+	mac.Write(head)
+	mac.Write(crypted)
+	if !hmac.Equal(mac.Sum(nil), sum) {
+		return nil, ErrCryptAuth
+	}
+	plain, erro := aes.AesDecrypt(crypted, c.key)
+	if erro != nil || len(plain) < cryptNonceSize {
+		return nil, ErrCryptAuth
+	}
+	return plain[cryptNonceSize:], nil
+}
+
+// Read 实现io.Reader接口 按顺序输出所有帧的明文
+func (c *CryptReader) Read(p []byte) (int, error) {
+	for len(c.pending) == 0 {
+		frame, erro := c.ReadFrame()
+		if erro != nil {
+			return 0, erro
+		}
+		c.pending = frame
+	}
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
@@ -0,0 +1,117 @@
+package log
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeCryptFile 使用WriteIO加密写入lines 返回last.log的内容
+func writeCryptFile(t *testing.T, key []byte, lines ...string) []byte {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "logcrypt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	w := NewWriteIO(dir+string(filepath.Separator), "audit_", 64*MB, 3)
+	if err := w.SetEncryptKey(key); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range lines {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Close()
+	data, err := ioutil.ReadFile(filepath.Join(dir, "audit_last.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestCryptRoundTrip(t *testing.T) {
+	key := []byte("secret")
+	data := writeCryptFile(t, key, "first line\n", "second line\n", "\n")
+	if !bytes.HasPrefix(data, []byte(cryptMagic)) {
+		t.Fatalf("missing header: %q", data[:len(cryptMagic)])
+	}
+	if bytes.Contains(data, []byte("first line")) {
+		t.Fatal("plain text found in encrypted file")
+	}
+	plain, err := ioutil.ReadAll(NewCryptReader(bytes.NewReader(data), key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "first line\nsecond line\n\n"; string(plain) != want {
+		t.Fatalf("got %q, want %q", plain, want)
+	}
+}
+
+func TestCryptSameLineDiffers(t *testing.T) {
+	data := writeCryptFile(t, []byte("secret"), "same\n", "same\n")
+	r := bytes.NewReader(data[len(cryptMagic):])
+	var frames [][]byte
+	for r.Len() > 0 {
+		head := make([]byte, 4)
+		io.ReadFull(r, head)
+		body := make([]byte, int(binary.BigEndian.Uint32(head))+cryptMacSize)
+		io.ReadFull(r, body)
+		frames = append(frames, body)
+	}
+	if len(frames) != 2 || bytes.Equal(frames[0], frames[1]) {
+		t.Fatalf("identical lines should give different frames")
+	}
+}
+
+func TestCryptTruncated(t *testing.T) {
+	key := []byte("secret")
+	data := writeCryptFile(t, key, "complete\n", "partial\n")
+	for _, cut := range []int{1, cryptMacSize, cryptMacSize + 10} {
+		r := NewCryptReader(bytes.NewReader(data[:len(data)-cut]), key)
+		frame, err := r.ReadFrame()
+		if err != nil || string(frame) != "complete\n" {
+			t.Fatalf("cut %d: first frame %q, %v", cut, frame, err)
+		}
+		if _, err := r.ReadFrame(); err != ErrCryptTruncated {
+			t.Fatalf("cut %d: got %v, want ErrCryptTruncated", cut, err)
+		}
+	}
+	// 只写了一部分文件头
+	if _, err := NewCryptReader(bytes.NewReader([]byte(cryptMagic[:3])), key).ReadFrame(); err != ErrCryptTruncated {
+		t.Fatalf("partial header: got %v", err)
+	}
+}
+
+func TestCryptBadHMAC(t *testing.T) {
+	key := []byte("secret")
+	data := writeCryptFile(t, key, "line\n")
+	for _, pos := range []int{len(cryptMagic) + 4, len(data) - 1} {
+		bad := append([]byte{}, data...)
+		bad[pos] ^= 0xff
+		if _, err := NewCryptReader(bytes.NewReader(bad), key).ReadFrame(); err != ErrCryptAuth {
+			t.Fatalf("flip at %d: got %v, want ErrCryptAuth", pos, err)
+		}
+	}
+}
+
+func TestCryptWrongKey(t *testing.T) {
+	data := writeCryptFile(t, []byte("secret"), "line\n")
+	if _, err := NewCryptReader(bytes.NewReader(data), []byte("other")).ReadFrame(); err != ErrCryptAuth {
+		t.Fatalf("got %v, want ErrCryptAuth", err)
+	}
+}
+
+func TestCryptBadHeader(t *testing.T) {
+	if _, err := NewCryptReader(bytes.NewReader([]byte("plain log line\n")), []byte("k")).ReadFrame(); err != ErrCryptHeader {
+		t.Fatalf("got %v, want ErrCryptHeader", err)
+	}
+	if _, err := NewCryptReader(bytes.NewReader(nil), []byte("k")).ReadFrame(); err != io.EOF {
+		t.Fatalf("empty file: got %v, want io.EOF", err)
+	}
+}
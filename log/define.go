@@ -1,6 +1,13 @@
 package log
 
-import "fmt"
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/go-irain/tools/config"
+)
 
 // 日志大小单位
 const (
@@ -34,6 +41,21 @@ func (level Level) String() string {
 	}
 }
 
+// ParseLevel 解析日志等级 支持debug/info/warn/error和String()输出的缩写 不区分大小写
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug", "dbug":
+		return L_DEBUG, nil
+	case "info":
+		return L_INFO, nil
+	case "warn", "warning", "warnning":
+		return L_WARN, nil
+	case "error", "erro":
+		return L_ERROR, nil
+	}
+	return L_DEBUG, fmt.Errorf("unknown log level %q", s)
+}
+
 // 默认自动创建一个全局的log 直接使用
 var golbalLogger *Log
 
@@ -69,82 +91,146 @@ func SetOutDirConfig(path string, maxsize int, maxcount int) {
 	golbalLogger.SetOutDirConfig(path, maxsize, maxcount)
 }
 
+// SetTagEncryptKey 设置tag日志文件加密写入
+func SetTagEncryptKey(tag string, key []byte) error {
+	return golbalLogger.SetTagEncryptKey(tag, key)
+}
+
+// Enabled 判断全局log是否输出level等级的日志
+func Enabled(level Level) bool {
+	return golbalLogger.Enabled(level)
+}
+
+// TagEnabled 判断全局log的tag是否输出level等级的日志
+func TagEnabled(tag string, level Level) bool {
+	return golbalLogger.TagEnabled(tag, level)
+}
+
+// SetTagLevel 设置tag单独的日志等级
+func SetTagLevel(tag string, level Level) error {
+	return golbalLogger.SetTagLevel(tag, level)
+}
+
+// SetTagOutConfig 设置tag单独的文件大小和数量
+func SetTagOutConfig(tag string, maxsize int, maxcount int) error {
+	return golbalLogger.SetTagOutConfig(tag, maxsize, maxcount)
+}
+
+// SetTimeFormat 设置全局log时间的格式
+func SetTimeFormat(layout string) {
+	golbalLogger.SetTimeFormat(layout)
+}
+
+// SetTimeLocation 设置全局log时间的时区
+func SetTimeLocation(loc *time.Location) {
+	golbalLogger.SetTimeLocation(loc)
+}
+
+// Helper 把调用Helper的函数标记为日志辅助函数 显示行号时跳过
+func Helper() {
+	var pcs [1]uintptr
+	if runtime.Callers(2, pcs[:]) == 0 {
+		return
+	}
+	golbalLogger.markHelper(pcs[0])
+}
+
+// AddCallerSkip 额外跳过n层调用 用于包装全局log
+func AddCallerSkip(n int) {
+	golbalLogger.AddCallerSkip(n)
+}
+
+// SetCallerFormat 设置行号信息的显示方式
+func SetCallerFormat(format CallerFormat) {
+	golbalLogger.SetCallerFormat(format)
+}
+
+// Configure 根据配置块设置全局log
+func Configure(sec config.ConfigSection) error {
+	return golbalLogger.Configure(sec)
+}
+
+// ConfigureSection 根据已加载配置中的[name]和[name.tag.TAG]块设置全局log
+func ConfigureSection(name string) error {
+	return golbalLogger.ConfigureSection(name)
+}
+
 // TagDebug 调试输出
 func TagDebug(tag string, a ...interface{}) {
-	golbalLogger.Output(tag, L_DEBUG, 2, fmt.Sprintln(a...))
+	golbalLogger.output(tag, L_DEBUG, 2, msgPrint, "", a)
 }
 
 // TagInfo 普通信息
 func TagInfo(tag string, a ...interface{}) {
-	golbalLogger.Output(tag, L_INFO, 2, fmt.Sprintln(a...))
+	golbalLogger.output(tag, L_INFO, 2, msgPrint, "", a)
 }
 
 // TagWarnning 警告
 func TagWarnning(tag string, a ...interface{}) {
-	golbalLogger.Output(tag, L_WARN, 2, fmt.Sprintln(a...))
+	golbalLogger.output(tag, L_WARN, 2, msgPrint, "", a)
 }
 
 // TagError 错误
 func TagError(tag string, a ...interface{}) {
-	golbalLogger.Output(tag, L_ERROR, 2, fmt.Sprintln(a...))
+	golbalLogger.output(tag, L_ERROR, 2, msgPrint, "", a)
 }
 
 // TagDebugf 格式化debug输出
 func TagDebugf(tag, format string, a ...interface{}) {
-	golbalLogger.Output(tag, L_DEBUG, 2, fmt.Sprintf(format, a...))
+	golbalLogger.output(tag, L_DEBUG, 2, msgPrintf, format, a)
 }
 
 // TagInfof 格式化info输出
 func TagInfof(tag, format string, a ...interface{}) {
-	golbalLogger.Output(tag, L_INFO, 2, fmt.Sprintf(format, a...))
+	golbalLogger.output(tag, L_INFO, 2, msgPrintf, format, a)
 }
 
 // TagWarnningf 格式化warnning输出
 func TagWarnningf(tag, format string, a ...interface{}) {
-	golbalLogger.Output(tag, L_WARN, 2, fmt.Sprintf(format, a...))
+	golbalLogger.output(tag, L_WARN, 2, msgPrintf, format, a)
 }
 
 // TagErrorf 格式化error输出
 func TagErrorf(tag, format string, a ...interface{}) {
-	golbalLogger.Output(tag, L_ERROR, 2, fmt.Sprintf(format, a...))
+	golbalLogger.output(tag, L_ERROR, 2, msgPrintf, format, a)
 }
 
 // Debug 调试输出
 func Debug(a ...interface{}) {
-	golbalLogger.TagDebug(golbalLogger.defaultTagName, a...)
+	golbalLogger.output(golbalLogger.defaultTagName, L_DEBUG, 2, msgPrint, "", a)
 }
 
 // Info 普通信息
 func Info(a ...interface{}) {
-	golbalLogger.TagInfo(golbalLogger.defaultTagName, a...)
+	golbalLogger.output(golbalLogger.defaultTagName, L_INFO, 2, msgPrint, "", a)
 }
 
 // Warnning 警告
 func Warnning(a ...interface{}) {
-	golbalLogger.TagWarnning(golbalLogger.defaultTagName, a...)
+	golbalLogger.output(golbalLogger.defaultTagName, L_WARN, 2, msgPrint, "", a)
 }
 
 // Error 错误
 func Error(a ...interface{}) {
-	golbalLogger.TagError(golbalLogger.defaultTagName, a...)
+	golbalLogger.output(golbalLogger.defaultTagName, L_ERROR, 2, msgPrint, "", a)
 }
 
 // Debugf 格式化debug输出
 func Debugf(format string, a ...interface{}) {
-	golbalLogger.TagDebugf(golbalLogger.defaultTagName, format, a...)
+	golbalLogger.output(golbalLogger.defaultTagName, L_DEBUG, 2, msgPrintf, format, a)
 }
 
 // Infof 格式化info输出
 func Infof(format string, a ...interface{}) {
-	golbalLogger.TagInfof(golbalLogger.defaultTagName, format, a...)
+	golbalLogger.output(golbalLogger.defaultTagName, L_INFO, 2, msgPrintf, format, a)
 }
 
 // Warnningf 格式化warnning输出
 func Warnningf(format string, a ...interface{}) {
-	golbalLogger.TagWarnningf(golbalLogger.defaultTagName, format, a...)
+	golbalLogger.output(golbalLogger.defaultTagName, L_WARN, 2, msgPrintf, format, a)
 }
 
 // Errorf 格式化error输出
 func Errorf(format string, a ...interface{}) {
-	golbalLogger.TagErrorf(golbalLogger.defaultTagName, format, a...)
+	golbalLogger.output(golbalLogger.defaultTagName, L_ERROR, 2, msgPrintf, format, a)
 }
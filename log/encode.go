@@ -0,0 +1,59 @@
+package log
+
+import (
+	"fmt"
+	"sync"
+)
+
+// buffer 一条日志的编码缓冲 通过append拼接 避免中间字符串
+type buffer []byte
+
+// Write 实现io.Writer 用于fmt.Fprint系列直接写入缓冲
+func (b *buffer) Write(p []byte) (int, error) {
+	*b = append(*b, p...)
+	return len(p), nil
+}
+
+// 超过这个大小的缓冲不放回池子 避免偶尔的大日志一直占用内存
+const maxPoolBuffer = 64 * KB
+
+var bufferPool = sync.Pool{New: func() interface{} {
+	b := make(buffer, 0, 512)
+	return &b
+}}
+
+func getBuffer() *buffer {
+	b := bufferPool.Get().(*buffer)
+	*b = (*b)[:0]
+	return b
+}
+
+func putBuffer(b *buffer) {
+	if cap(*b) <= int(maxPoolBuffer) {
+		bufferPool.Put(b)
+	}
+}
+
+// 消息内容的格式化方式
+type msgKind uint8
+
+const (
+	msgLiteral msgKind = iota // 原样输出字符串
+	msgPrint                  // fmt.Sprintln
+	msgPrintf                 // fmt.Sprintf
+)
+
+// appendMessage 把消息追加到缓冲 并保证以换行结尾
+func (b *buffer) appendMessage(kind msgKind, format string, a []interface{}) {
+	switch kind {
+	case msgPrint:
+		fmt.Fprintln(b, a...)
+	case msgPrintf:
+		fmt.Fprintf(b, format, a...)
+	default:
+		*b = append(*b, format...)
+	}
+	if n := len(*b); n == 0 || (*b)[n-1] != '\n' {
+		*b = append(*b, '\n')
+	}
+}
@@ -15,6 +15,11 @@ func main() {
 	// log.SetOutDirConfig("log", 100, 10)
 	// 设置标签前缀 默认会自动添加应用的名称作为前缀
 	log.SetTags("encode", "decode")
+	// 也可以从配置文件的[log]和[log.tag.NAME]块读取以上设置
+	// config.SetConfig("app.ini")
+	// log.ConfigureSection("log")
+	// 设置标签日志文件加密写入 使用cmd/logdecrypt查看
+	// log.SetTagEncryptKey("decode", []byte("secret"))
 	// 设置钩子对error等级的日志进行处理
 	log.SetHook(log.L_ERROR, func(tag, message string) {
 		fmt.Println("hook >>>", tag, message)
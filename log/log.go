@@ -3,15 +3,13 @@
 package log
 
 import (
-	"bytes"
 	"errors"
-	"fmt"
 	"io"
 	"os"
 	"path/filepath"
-	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -34,19 +32,36 @@ type Log struct {
 	// 随着代码版本的不同 记录的文件行号也不会准确
 	showFileline bool
 
-	// 日志等级
+	// 日志等级 原子读写 输出时不需要加锁判断
 	level Level
+	// tag单独的日志等级 map[string]Level 修改时整体替换
+	taglevels atomic.Value
+
+	// 行号信息的显示方式和额外跳过的调用层数
+	callerformat CallerFormat
+	callerskip   int
+	// 通过Helper标记的函数名 显示行号时跳过
+	helpers   sync.Map
+	hasHelper bool
 
 	// 负责写文件
 	// 一个日志可能会写不同的文件名
 	tags           map[string]io.Writer
 	defaultTagName string
 
-	bufpool *sync.Pool
+	// 时间的显示格式和时区
+	timeformat *timeFormat
+	// 输出时使用的设置副本 *outputOptions
+	options atomic.Value
+	// 当前这一秒格式化好的时间 *timeCache
+	timecache atomic.Value
 
 	// filter 等级过滤器 可以自定义一些处理
 	filters map[Level]func(string, string)
 
+	// tag单独的配置 覆盖全局设置
+	tagconfs map[string]*tagConfig
+
 	lock sync.Mutex
 }
 
@@ -54,18 +69,38 @@ type Log struct {
 func NewLog() *Log {
 	l := &Log{
 		filters:        make(map[Level]func(string, string)),
+		tagconfs:       make(map[string]*tagConfig),
 		showFileline:   true,
+		timeformat:     newTimeFormat(TimeDefault, nil),
 		level:          L_DEBUG,
 		tags:           make(map[string]io.Writer),
 		defaultTagName: (strings.Split(filepath.Base(os.Args[0]), "."))[0],
-		bufpool: &sync.Pool{New: func() interface{} {
-			return bytes.NewBuffer([]byte{})
-		}},
 	}
+	l.storeOptions()
 	l.SetTags(l.defaultTagName)
 	return l
 }
 
+// outputOptions 输出一条日志需要的设置 修改时整体替换 输出时不加锁读取
+type outputOptions struct {
+	showline  bool
+	format    CallerFormat
+	skip      int
+	hasHelper bool
+	time      *timeFormat
+}
+
+// storeOptions 设置修改后更新输出使用的副本 调用方需要持有锁
+func (log *Log) storeOptions() {
+	log.options.Store(&outputOptions{
+		showline:  log.showFileline,
+		format:    log.callerformat,
+		skip:      log.callerskip,
+		hasHelper: log.hasHelper,
+		time:      log.timeformat,
+	})
+}
+
 var logpaths = []string{}
 var timenowfunc = time.Now
 
@@ -84,12 +119,66 @@ func (log *Log) SetTags(tagnames ...string) error {
 		if log.path == "" {
 			log.tags[name] = os.Stdout
 		} else {
-			log.tags[name] = NewWriteIO(log.path, name+"_", log.maxsize, log.maxcoutnum)
+			log.tags[name] = log.newTagWriteIO(name)
 		}
 	}
 	return nil
 }
 
+// tagConfig tag单独的配置 零值表示使用全局设置
+type tagConfig struct {
+	maxsize  int64
+	maxcount int
+	cryptkey []byte
+}
+
+// tagConf 获取tag的配置 不存在则创建 调用方需要持有锁
+func (log *Log) tagConf(name string) *tagConfig {
+	conf, ok := log.tagconfs[name]
+	if !ok {
+		conf = &tagConfig{}
+		log.tagconfs[name] = conf
+	}
+	return conf
+}
+
+// newTagWriteIO 创建tag对应的文件输出 调用方需要持有锁
+func (log *Log) newTagWriteIO(name string) *WriteIO {
+	maxsize, maxcount := log.maxsize, log.maxcoutnum
+	conf, ok := log.tagconfs[name]
+	if ok && conf.maxsize > 0 {
+		maxsize = conf.maxsize
+	}
+	if ok && conf.maxcount > 0 {
+		maxcount = conf.maxcount
+	}
+	w := NewWriteIO(log.path, name+"_", maxsize, maxcount)
+	if ok && conf.cryptkey != nil {
+		w.SetEncryptKey(conf.cryptkey)
+	}
+	return w
+}
+
+// SetTagEncryptKey 设置tag日志文件加密写入
+// 只对输出到文件的日志生效 控制台输出始终为明文
+// 可以在SetOutDirConfig之前或之后调用
+func (log *Log) SetTagEncryptKey(tag string, key []byte) error {
+	log.lock.Lock()
+	defer log.lock.Unlock()
+	if len(key) == 0 {
+		return errors.New("SetTagEncryptKey key is empty")
+	}
+	out, ok := log.tags[tag]
+	if !ok {
+		return errors.New("SetTagEncryptKey tag is not exist:" + tag)
+	}
+	log.tagConf(tag).cryptkey = key
+	if w, ok := out.(*WriteIO); ok {
+		return w.SetEncryptKey(key)
+	}
+	return nil
+}
+
 // SetLevel 设置日志等级
 func (log *Log) SetLevel(level Level) {
 	log.lock.Lock()
@@ -97,7 +186,50 @@ func (log *Log) SetLevel(level Level) {
 	if level > L_ERROR {
 		level = L_ERROR
 	}
-	log.level = level
+	atomic.StoreUint32((*uint32)(&log.level), uint32(level))
+}
+
+// SetTagLevel 设置tag单独的日志等级 覆盖SetLevel的设置
+func (log *Log) SetTagLevel(tag string, level Level) error {
+	log.lock.Lock()
+	defer log.lock.Unlock()
+	if _, ok := log.tags[tag]; !ok {
+		return errors.New("SetTagLevel tag is not exist:" + tag)
+	}
+	if level > L_ERROR {
+		level = L_ERROR
+	}
+	old, _ := log.taglevels.Load().(map[string]Level)
+	levels := make(map[string]Level, len(old)+1)
+	for k, v := range old {
+		levels[k] = v
+	}
+	levels[tag] = level
+	log.taglevels.Store(levels)
+	return nil
+}
+
+// SetTagOutConfig 设置tag单独的文件大小和数量 单位同SetOutDirConfig
+// 小于等于0表示使用全局设置 已经打开的文件输出会重新创建
+func (log *Log) SetTagOutConfig(tag string, maxsize int, maxcount int) error {
+	log.lock.Lock()
+	defer log.lock.Unlock()
+	if _, ok := log.tags[tag]; !ok {
+		return errors.New("SetTagOutConfig tag is not exist:" + tag)
+	}
+	conf := log.tagConf(tag)
+	conf.maxsize, conf.maxcount = 0, 0
+	if maxsize > 0 {
+		conf.maxsize = int64(maxsize) * MB
+	}
+	if maxcount > 0 {
+		conf.maxcount = maxcount
+	}
+	if w, ok := log.tags[tag].(*WriteIO); ok {
+		w.Close()
+		log.tags[tag] = log.newTagWriteIO(tag)
+	}
+	return nil
 }
 
 // SetShowLineNumber 是否显示行号
@@ -105,6 +237,7 @@ func (log *Log) SetShowLineNumber(show bool) {
 	log.lock.Lock()
 	defer log.lock.Unlock()
 	log.showFileline = show
+	log.storeOptions()
 }
 
 // SetHook 钩子 可以对指定的等级log进行自定义处理
@@ -128,155 +261,195 @@ func (log *Log) SetOutDirConfig(path string, maxsize int, maxcount int) {
 	defer log.lock.Unlock()
 	path, _ = filepath.Abs(path)
 	println("log SetOutDir:", path)
+	if erro := checkOutDir(path); erro != nil {
+		println("log SetOutDir error:" + erro.Error())
+		os.Exit(1)
+	}
+	log.setOutDir(path, maxsize, maxcount)
+}
+
+// checkOutDir 检查输出目录没有被使用过 不存在时创建 path为绝对路径
+func checkOutDir(path string) error {
 	for i := range logpaths {
 		if logpaths[i] == path {
-			println("log SetOutDir error: logpath is exist ->" + path)
-			os.Exit(1)
+			return errors.New("logpath is exist ->" + path)
 		}
 	}
 	erro := os.MkdirAll(path, 0777)
 	if erro != nil {
-		println("log SetOutDir error:" + erro.Error())
-		os.Exit(1)
+		return erro
 	}
-	logpaths = append(logpaths, path)
 	fi, erro := os.Stat(path)
 	if erro != nil && os.IsNotExist(erro) || erro == nil && !fi.IsDir() {
-		println("log SetOutDir error:path is not a dir")
-		os.Exit(1)
+		return errors.New("path is not a dir")
 	}
 	if fi.Mode().Perm() < 0666 {
-		println("log SetOutDir error:path no permissions to read and write")
-		os.Exit(1)
+		return errors.New("path no permissions to read and write")
 	}
+	return nil
+}
+
+// setOutDir 记录输出目录并为每个tag创建文件输出 调用方需要持有锁并已经通过checkOutDir检查
+func (log *Log) setOutDir(path string, maxsize int, maxcount int) {
+	logpaths = append(logpaths, path)
 	log.path = filepath.Clean(path) + string(filepath.Separator)
+	log.setOutSize(maxsize, maxcount)
+}
+
+// setOutSize 修改全局的文件大小和数量 重新创建每个tag的文件输出 调用方需要持有锁
+func (log *Log) setOutSize(maxsize int, maxcount int) {
 	log.maxsize = int64(maxsize) * MB
 	log.maxcoutnum = maxcount
-	for name := range log.tags {
-		log.tags[name] = NewWriteIO(log.path, name+"_", log.maxsize, log.maxcoutnum)
+	for name, out := range log.tags {
+		if w, ok := out.(*WriteIO); ok {
+			w.Close()
+		}
+		log.tags[name] = log.newTagWriteIO(name)
 	}
 }
 
+// Output 输出一条日志
+// calldepth和runtime.Caller相同 1表示Output的调用方 2表示再上一层
+// 本包提供的输出方法都使用2 即调用这些方法的位置
 func (log *Log) Output(tag string, level Level, calldepth int, str string) {
-	if level > L_ERROR || level < log.level {
-		return
+	log.output(tag, level, calldepth+1, msgLiteral, str, nil)
+}
+
+// Enabled 判断level等级的日志是否会输出
+// 参数需要额外计算时可以先调用Enabled判断
+func (log *Log) Enabled(level Level) bool {
+	return level <= L_ERROR && level >= Level(atomic.LoadUint32((*uint32)(&log.level)))
+}
+
+// TagEnabled 判断tag下level等级的日志是否会输出 考虑SetTagLevel的设置
+func (log *Log) TagEnabled(tag string, level Level) bool {
+	if level > L_ERROR {
+		return false
 	}
-	buf := log.bufpool.Get().(*bytes.Buffer)
-	buf.Reset()
-	buf.WriteString(timenowfunc().Format("2006/01/02 15:04:05"))
-	buf.WriteString(fmt.Sprintf(" [%s] <%s> ", level, tag))
-	if log.showFileline {
-		_, file, line, ok := runtime.Caller(calldepth)
-		if !ok {
-			file = "???"
-			line = 0
+	if levels, ok := log.taglevels.Load().(map[string]Level); ok {
+		if min, ok := levels[tag]; ok {
+			return level >= min
 		}
-		length := len(file) - 1
-		for i := length; i > 0; i-- {
-			if file[i] == '/' {
-				file = file[i+1 : length-2]
-				break
-			}
-		}
-		buf.WriteString(fmt.Sprintf("%s:%d ", file, line))
 	}
-	if len(str) == 0 || str[len(str)-1] != '\n' {
-		str += "\n"
+	return level >= Level(atomic.LoadUint32((*uint32)(&log.level)))
+}
+
+// output 编码并写入一条日志
+// 先判断等级再格式化参数 被过滤的日志不产生任何分配
+// 编码时不加锁 只在写入时加锁一次
+func (log *Log) output(tag string, level Level, calldepth int, kind msgKind, format string, a []interface{}) {
+	if !log.TagEnabled(tag, level) {
+		return
 	}
-	buf.WriteString(str)
+	opts := log.options.Load().(*outputOptions)
+
+	buf := getBuffer()
+	*buf = log.appendTime(*buf, opts.time, timenowfunc())
+	*buf = append(*buf, " ["...)
+	*buf = append(*buf, level.String()...)
+	*buf = append(*buf, "] <"...)
+	*buf = append(*buf, tag...)
+	*buf = append(*buf, "> "...)
+	if opts.showline {
+		*buf = log.appendCaller(*buf, calldepth+opts.skip, opts.format, opts.hasHelper)
+		*buf = append(*buf, ' ')
+	}
+	start := len(*buf)
+	buf.appendMessage(kind, format, a)
+
 	log.lock.Lock()
 	filter, fok := log.filters[level]
 	out, ok := log.tags[tag]
 	if !ok {
 		out = log.tags[log.defaultTagName]
 	}
-	_, erro := buf.WriteTo(out)
+	_, erro := out.Write(*buf)
 	log.lock.Unlock()
 	if fok {
-		filter(tag, str)
+		filter(tag, string((*buf)[start:]))
 	}
 	if erro != nil {
 		println(erro.Error())
 	}
-	log.bufpool.Put(buf)
+	putBuffer(buf)
 }
 
 // TagDebug 调试输出
 func (log *Log) TagDebug(tag string, a ...interface{}) {
-	log.Output(tag, L_DEBUG, 3, fmt.Sprintln(a...))
+	log.output(tag, L_DEBUG, 2, msgPrint, "", a)
 }
 
 // TagInfo 普通信息
 func (log *Log) TagInfo(tag string, a ...interface{}) {
-	log.Output(tag, L_INFO, 3, fmt.Sprintln(a...))
+	log.output(tag, L_INFO, 2, msgPrint, "", a)
 }
 
 // TagWarnning 警告
 func (log *Log) TagWarnning(tag string, a ...interface{}) {
-	log.Output(tag, L_WARN, 3, fmt.Sprintln(a...))
+	log.output(tag, L_WARN, 2, msgPrint, "", a)
 }
 
 // TagError 错误
 func (log *Log) TagError(tag string, a ...interface{}) {
-	log.Output(tag, L_ERROR, 3, fmt.Sprintln(a...))
+	log.output(tag, L_ERROR, 2, msgPrint, "", a)
 }
 
 // TagDebugf 格式化debug输出
 func (log *Log) TagDebugf(tag, format string, a ...interface{}) {
-	log.Output(tag, L_DEBUG, 3, fmt.Sprintf(format, a...))
+	log.output(tag, L_DEBUG, 2, msgPrintf, format, a)
 }
 
 // TagInfof 格式化info输出
 func (log *Log) TagInfof(tag, format string, a ...interface{}) {
-	log.Output(tag, L_INFO, 3, fmt.Sprintf(format, a...))
+	log.output(tag, L_INFO, 2, msgPrintf, format, a)
 }
 
 // TagWarnningf 格式化warnning输出
 func (log *Log) TagWarnningf(tag, format string, a ...interface{}) {
-	log.Output(tag, L_WARN, 3, fmt.Sprintf(format, a...))
+	log.output(tag, L_WARN, 2, msgPrintf, format, a)
 }
 
 // TagErrorf 格式化error输出
 func (log *Log) TagErrorf(tag, format string, a ...interface{}) {
-	log.Output(tag, L_ERROR, 3, fmt.Sprintf(format, a...))
+	log.output(tag, L_ERROR, 2, msgPrintf, format, a)
 }
 
 // Debug 调试输出
 func (log *Log) Debug(a ...interface{}) {
-	log.TagDebug(log.defaultTagName, a...)
+	log.output(log.defaultTagName, L_DEBUG, 2, msgPrint, "", a)
 }
 
 // Info 普通信息
 func (log *Log) Info(a ...interface{}) {
-	log.TagInfo(log.defaultTagName, a...)
+	log.output(log.defaultTagName, L_INFO, 2, msgPrint, "", a)
 }
 
 // Warnning 警告
 func (log *Log) Warnning(a ...interface{}) {
-	log.TagWarnning(log.defaultTagName, a...)
+	log.output(log.defaultTagName, L_WARN, 2, msgPrint, "", a)
 }
 
 // Error 错误
 func (log *Log) Error(a ...interface{}) {
-	log.TagError(log.defaultTagName, a...)
+	log.output(log.defaultTagName, L_ERROR, 2, msgPrint, "", a)
 }
 
 // Debugf 格式化debug输出
 func (log *Log) Debugf(format string, a ...interface{}) {
-	log.TagDebugf(log.defaultTagName, format, a...)
+	log.output(log.defaultTagName, L_DEBUG, 2, msgPrintf, format, a)
 }
 
 // Infof 格式化info输出
 func (log *Log) Infof(format string, a ...interface{}) {
-	log.TagInfof(log.defaultTagName, format, a...)
+	log.output(log.defaultTagName, L_INFO, 2, msgPrintf, format, a)
 }
 
 // Warnningf 格式化warnning输出
 func (log *Log) Warnningf(format string, a ...interface{}) {
-	log.TagWarnningf(log.defaultTagName, format, a...)
+	log.output(log.defaultTagName, L_WARN, 2, msgPrintf, format, a)
 }
 
 // Errorf 格式化error输出
 func (log *Log) Errorf(format string, a ...interface{}) {
-	log.TagErrorf(log.defaultTagName, format, a...)
+	log.output(log.defaultTagName, L_ERROR, 2, msgPrintf, format, a)
 }
@@ -0,0 +1,202 @@
+package log
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestLog 返回默认tag写入w的Log
+func newTestLog(w io.Writer) *Log {
+	log := NewLog()
+	log.tags[log.defaultTagName] = w
+	return log
+}
+
+func fixTime(t *testing.T) {
+	now := time.Date(2024, 5, 6, 7, 8, 9, 123456789, time.UTC)
+	timenowfunc = func() time.Time { return now }
+	t.Cleanup(func() { timenowfunc = time.Now })
+}
+
+func TestOutputFormat(t *testing.T) {
+	fixTime(t)
+	var buf bytes.Buffer
+	log := newTestLog(&buf)
+	log.SetTimeLocation(time.UTC)
+	log.SetShowLineNumber(false)
+	log.Info("hello", 42)
+	log.Errorf("n=%d", 7)
+	log.Debugf("no newline added twice\n")
+	want := "2024/05/06 07:08:09 [INFO] <" + log.defaultTagName + "> hello 42\n" +
+		"2024/05/06 07:08:09 [ERRO] <" + log.defaultTagName + "> n=7\n" +
+		"2024/05/06 07:08:09 [DBUG] <" + log.defaultTagName + "> no newline added twice\n"
+	if buf.String() != want {
+		t.Fatalf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestOutputCaller(t *testing.T) {
+	var buf bytes.Buffer
+	log := newTestLog(&buf)
+	log.Info("x")
+	if !regexp.MustCompile(`> log_test:\d+ x\n$`).Match(buf.Bytes()) {
+		t.Fatalf("caller missing: %q", buf.String())
+	}
+	buf.Reset()
+	log.SetCallerFormat(C_FUNC)
+	log.Info("x")
+	if !regexp.MustCompile(`> log\.TestOutputCaller:\d+ x\n$`).Match(buf.Bytes()) {
+		t.Fatalf("func caller missing: %q", buf.String())
+	}
+}
+
+// logHelper 通过Helper标记的辅助函数 再经过一层辅助函数输出
+func logHelper(log *Log, msg string) {
+	log.Helper()
+	logHelperInner(log, msg)
+}
+
+func logHelperInner(log *Log, msg string) {
+	log.Helper()
+	log.Info(msg)
+}
+
+// logWrapper 没有标记Helper的包装函数 通过AddCallerSkip跳过
+func logWrapper(log *Log, msg string) {
+	log.Info(msg)
+}
+
+// here 返回调用方的文件和下一行的行号
+func here() (string, int) {
+	_, file, line, _ := runtime.Caller(1)
+	return file, line + 1
+}
+
+func TestOutputCallerSkip(t *testing.T) {
+	var buf bytes.Buffer
+	log := newTestLog(&buf)
+	log.SetCallerFormat(C_FULL)
+
+	file, line := here()
+	log.Info("x")
+	if want := "> " + file + ":" + strconv.Itoa(line) + " x\n"; !strings.HasSuffix(buf.String(), want) {
+		t.Errorf("full: got %q, want suffix %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	_, line = here()
+	logHelper(log, "x")
+	if want := "> " + file + ":" + strconv.Itoa(line) + " x\n"; !strings.HasSuffix(buf.String(), want) {
+		t.Errorf("helper: got %q, want suffix %q", buf.String(), want)
+	}
+
+	// 没有AddCallerSkip时显示包装函数中的位置
+	buf.Reset()
+	logWrapper(log, "x")
+	if !strings.Contains(buf.String(), "log_test.go:") || strings.HasSuffix(buf.String(), ":"+strconv.Itoa(line)+" x\n") {
+		t.Errorf("wrapper without skip: %q", buf.String())
+	}
+	buf.Reset()
+	log.AddCallerSkip(1)
+	_, line = here()
+	logWrapper(log, "x")
+	if want := "> " + file + ":" + strconv.Itoa(line) + " x\n"; !strings.HasSuffix(buf.String(), want) {
+		t.Errorf("wrapper: got %q, want suffix %q", buf.String(), want)
+	}
+	// 累加后小于0按0处理
+	buf.Reset()
+	log.AddCallerSkip(-5)
+	_, line = here()
+	log.Info("x")
+	if want := "> " + file + ":" + strconv.Itoa(line) + " x\n"; !strings.HasSuffix(buf.String(), want) {
+		t.Errorf("negative skip: got %q, want suffix %q", buf.String(), want)
+	}
+}
+
+func TestOutputFiltered(t *testing.T) {
+	var buf bytes.Buffer
+	log := newTestLog(&buf)
+	log.SetLevel(L_WARN)
+	log.Info("dropped")
+	if buf.Len() != 0 {
+		t.Fatalf("got %q", buf.String())
+	}
+	allocs := testing.AllocsPerRun(100, func() { log.Info("dropped", 1, "x") })
+	if allocs != 0 {
+		t.Fatalf("filtered log allocates %.0f times", allocs)
+	}
+}
+
+func BenchmarkOutput(b *testing.B) {
+	log := newTestLog(ioutil.Discard)
+	log.SetShowLineNumber(false)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		log.Output(log.defaultTagName, L_INFO, 1, "request done")
+	}
+}
+
+func BenchmarkOutputf(b *testing.B) {
+	log := newTestLog(ioutil.Discard)
+	log.SetShowLineNumber(false)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		log.Infof("request %s done in %d ms", "GET /", 12)
+	}
+}
+
+func BenchmarkOutputCaller(b *testing.B) {
+	log := newTestLog(ioutil.Discard)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		log.Output(log.defaultTagName, L_INFO, 1, "request done")
+	}
+}
+
+func BenchmarkOutputCallerHelper(b *testing.B) {
+	log := newTestLog(ioutil.Discard)
+	helper := func() {
+		log.Helper()
+		log.Output(log.defaultTagName, L_INFO, 2, "request done")
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		helper()
+	}
+}
+
+func BenchmarkOutputMilli(b *testing.B) {
+	log := newTestLog(ioutil.Discard)
+	log.SetShowLineNumber(false)
+	log.SetTimeFormat(TimeRFC3339Milli)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		log.Output(log.defaultTagName, L_INFO, 1, "request done")
+	}
+}
+
+func BenchmarkOutputFiltered(b *testing.B) {
+	log := newTestLog(ioutil.Discard)
+	log.SetLevel(L_ERROR)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		log.Info("dropped", i)
+	}
+}
+
+func BenchmarkOutputParallel(b *testing.B) {
+	log := newTestLog(ioutil.Discard)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			log.Output(log.defaultTagName, L_INFO, 1, "request done")
+		}
+	})
+}
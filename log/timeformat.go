@@ -0,0 +1,159 @@
+package log
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 常用的时间格式 也可以使用任意time包的layout
+const (
+	TimeDefault      = "2006/01/02 15:04:05"              // 默认格式 精确到秒
+	TimeRFC3339      = time.RFC3339                       // 2006-01-02T15:04:05Z07:00
+	TimeRFC3339Milli = "2006-01-02T15:04:05.000Z07:00"    // 毫秒
+	TimeRFC3339Micro = "2006-01-02T15:04:05.000000Z07:00" // 微秒
+	TimeUnix         = "unix"                             // unix时间戳 秒
+	TimeUnixMilli    = "unixmilli"                        // unix时间戳 毫秒
+	TimeUnixMicro    = "unixmicro"                        // unix时间戳 微秒
+)
+
+// timeFormat 时间格式 创建后不再修改 修改设置时整体替换
+type timeFormat struct {
+	layout string
+	loc    *time.Location
+	// unix时间戳的倍数 0表示使用layout
+	unixdiv int64
+	// layout只精确到秒 可以按秒缓存格式化结果
+	cacheable bool
+}
+
+func newTimeFormat(layout string, loc *time.Location) *timeFormat {
+	tf := &timeFormat{layout: layout, loc: loc}
+	switch layout {
+	case TimeUnix:
+		tf.unixdiv = int64(time.Second)
+	case TimeUnixMilli:
+		tf.unixdiv = int64(time.Millisecond)
+	case TimeUnixMicro:
+		tf.unixdiv = int64(time.Microsecond)
+	default:
+		tf.cacheable = !hasFracSecond(layout)
+	}
+	if tf.unixdiv == int64(time.Second) {
+		tf.cacheable = true
+	}
+	return tf
+}
+
+// hasFracSecond layout中是否有小数秒 规则和time包相同
+// .或,后面连续的0或9 并且之后不是数字 如.000 ,999 "2006.01.02"中的.01不是小数秒
+func hasFracSecond(layout string) bool {
+	for i := 0; i+1 < len(layout); i++ {
+		if layout[i] != '.' && layout[i] != ',' {
+			continue
+		}
+		ch := layout[i+1]
+		if ch != '0' && ch != '9' {
+			continue
+		}
+		j := i + 1
+		for j < len(layout) && layout[j] == ch {
+			j++
+		}
+		if j == len(layout) || layout[j] < '0' || layout[j] > '9' {
+			return true
+		}
+	}
+	return false
+}
+
+// SetTimeFormat 设置日志时间的格式
+// layout可以是TimeDefault等常量 或者任意time包的layout 为空时恢复默认
+func (log *Log) SetTimeFormat(layout string) {
+	if layout == "" {
+		layout = TimeDefault
+	}
+	log.lock.Lock()
+	defer log.lock.Unlock()
+	log.timeformat = newTimeFormat(layout, log.timeformat.loc)
+	log.storeOptions()
+}
+
+// SetTimeLocation 设置日志时间的时区 nil表示本地时区
+func (log *Log) SetTimeLocation(loc *time.Location) {
+	log.lock.Lock()
+	defer log.lock.Unlock()
+	log.timeformat = newTimeFormat(log.timeformat.layout, loc)
+	log.storeOptions()
+}
+
+// ParseTimeFormat 解析配置中的时间格式
+// 支持default rfc3339 rfc3339ms rfc3339us unix unixms unixus 其他值当作time包的layout
+func ParseTimeFormat(s string) string {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "default":
+		return TimeDefault
+	case "rfc3339":
+		return TimeRFC3339
+	case "rfc3339ms", "rfc3339milli":
+		return TimeRFC3339Milli
+	case "rfc3339us", "rfc3339micro":
+		return TimeRFC3339Micro
+	case "unix":
+		return TimeUnix
+	case "unixms", "unixmilli":
+		return TimeUnixMilli
+	case "unixus", "unixmicro":
+		return TimeUnixMicro
+	}
+	return s
+}
+
+// ParseTimeLocation 解析时区 支持UTC Local和IANA时区名 如Asia/Shanghai
+func ParseTimeLocation(s string) (*time.Location, error) {
+	switch strings.TrimSpace(s) {
+	case "", "Local", "local":
+		return nil, nil
+	case "UTC", "utc":
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(s)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", s)
+	}
+	return loc, nil
+}
+
+// timeCache 缓存当前这一秒格式化好的时间
+type timeCache struct {
+	format *timeFormat
+	sec    int64
+	text   []byte
+}
+
+// appendTime 按tf的设置追加时间
+// 只精确到秒的格式同一秒内只格式化一次 其他格式直接追加到缓冲 都不产生分配
+func (log *Log) appendTime(b []byte, tf *timeFormat, t time.Time) []byte {
+	if tf.loc != nil {
+		t = t.In(tf.loc)
+	}
+	if !tf.cacheable {
+		if tf.unixdiv > 0 {
+			return strconv.AppendInt(b, t.UnixNano()/tf.unixdiv, 10)
+		}
+		return t.AppendFormat(b, tf.layout)
+	}
+	sec := t.Unix()
+	if c, ok := log.timecache.Load().(*timeCache); ok && c.sec == sec && c.format == tf {
+		return append(b, c.text...)
+	}
+	var text []byte
+	if tf.unixdiv > 0 {
+		text = strconv.AppendInt(make([]byte, 0, 20), sec, 10)
+	} else {
+		text = t.AppendFormat(make([]byte, 0, 32), tf.layout)
+	}
+	log.timecache.Store(&timeCache{format: tf, sec: sec, text: text})
+	return append(b, text...)
+}
@@ -0,0 +1,83 @@
+package log
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHasFracSecond(t *testing.T) {
+	tests := []struct {
+		layout string
+		want   bool
+	}{
+		{TimeDefault, false},
+		{TimeRFC3339, false},
+		{TimeRFC3339Milli, true},
+		{TimeRFC3339Micro, true},
+		{"2006.01.02 15:04:05", false},
+		{"02.01.2006 15:04", false},
+		{"2006.01.02 15:04:05.000", true},
+		{"15:04:05,999", true},
+		{"15:04:05.9", true},
+		{"15:04:05.", false},
+	}
+	for _, tt := range tests {
+		if got := hasFracSecond(tt.layout); got != tt.want {
+			t.Errorf("hasFracSecond(%q) = %v, want %v", tt.layout, got, tt.want)
+		}
+		if got := newTimeFormat(tt.layout, nil).cacheable; got == tt.want {
+			t.Errorf("newTimeFormat(%q).cacheable = %v", tt.layout, got)
+		}
+	}
+}
+
+func TestAppendTime(t *testing.T) {
+	now := time.Date(2024, 5, 6, 7, 8, 9, 123456789, time.UTC)
+	shanghai := time.FixedZone("CST", 8*3600)
+	tests := []struct {
+		layout string
+		loc    *time.Location
+		want   string
+	}{
+		{TimeDefault, time.UTC, "2024/05/06 07:08:09"},
+		{TimeDefault, shanghai, "2024/05/06 15:08:09"},
+		{TimeRFC3339Milli, time.UTC, "2024-05-06T07:08:09.123Z"},
+		{TimeRFC3339Micro, shanghai, "2024-05-06T15:08:09.123456+08:00"},
+		{"2006.01.02 15:04:05", time.UTC, "2024.05.06 07:08:09"},
+		{TimeUnix, nil, "1714979289"},
+		{TimeUnixMilli, nil, "1714979289123"},
+		{TimeUnixMicro, nil, "1714979289123456"},
+	}
+	log := NewLog()
+	for _, tt := range tests {
+		tf := newTimeFormat(tt.layout, tt.loc)
+		// 第二次使用缓存
+		for i := 0; i < 2; i++ {
+			if got := string(log.appendTime(nil, tf, now)); got != tt.want {
+				t.Errorf("%s %v: got %q, want %q", tt.layout, tt.loc, got, tt.want)
+			}
+		}
+	}
+	// 缓存只在同一秒内有效
+	tf := newTimeFormat(TimeDefault, time.UTC)
+	log.appendTime(nil, tf, now)
+	if got := string(log.appendTime(nil, tf, now.Add(time.Second))); got != "2024/05/06 07:08:10" {
+		t.Errorf("next second: got %q", got)
+	}
+}
+
+func TestParseTimeFormat(t *testing.T) {
+	for in, want := range map[string]string{
+		"":           TimeDefault,
+		"RFC3339ms":  TimeRFC3339Milli,
+		"unixus":     TimeUnixMicro,
+		"2006-01-02": "2006-01-02",
+	} {
+		if got := ParseTimeFormat(in); got != want {
+			t.Errorf("ParseTimeFormat(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if _, err := ParseTimeLocation("Nowhere/City"); err == nil {
+		t.Error("unknown zone accepted")
+	}
+}
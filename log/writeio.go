@@ -1,6 +1,8 @@
 package log
 
 import (
+	"crypto/hmac"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -19,6 +21,10 @@ type WriteIO struct {
 	// 当前文件写入的大小
 	wsize int64
 	out   io.Writer
+
+	// 加密密钥 为空时写明文
+	cryptkey []byte
+	mackey   []byte
 }
 
 const minsize = 10
@@ -39,6 +45,25 @@ func NewWriteIO(dir, prefix string, maxsize int64, maxcount int) *WriteIO {
 	}
 }
 
+// SetEncryptKey 设置加密密钥 之后创建的日志文件都会加密写入
+// 格式见crypt.go 使用NewCryptReader或者cmd/logdecrypt读取
+// 已经存在的明文last.log会先被切分 避免同一个文件里混合明文和密文
+// 密钥没有变化时什么都不做 重新加载相同的配置不会切分文件
+func (o *WriteIO) SetEncryptKey(key []byte) error {
+	if len(key) == 0 {
+		return errors.New("log SetEncryptKey: key is empty")
+	}
+	if o.cryptkey != nil && hmac.Equal(o.cryptkey, key) {
+		return nil
+	}
+	o.cryptkey = key
+	o.mackey = cryptMacKey(key)
+	if o.out != nil {
+		return o.split()
+	}
+	return nil
+}
+
 func (o *WriteIO) split() error {
 	// 获取目录下指定前缀的所有日志文件
 	fs, erro := filepath.Glob(o.path + "*.log")
@@ -77,11 +102,45 @@ func (o *WriteIO) create() error {
 		if erro == nil {
 			o.wsize = info.Size()
 			o.out = f
+			if o.cryptkey != nil {
+				if o.wsize == 0 {
+					var n int
+					n, erro = f.Write([]byte(cryptMagic))
+					o.wsize += int64(n)
+				} else if !hasCryptHeader(o.path + "last.log") {
+					// 重启后遇到明文的last.log 切分出去再写密文
+					return o.split()
+				}
+			}
 		}
 	}
 	return erro
 }
 
+// Close 关闭当前打开的日志文件 之后再写入会重新打开
+func (o *WriteIO) Close() error {
+	if o.out == nil {
+		return nil
+	}
+	erro := o.out.(*os.File).Close()
+	o.out = nil
+	return erro
+}
+
+// hasCryptHeader 判断文件是否以加密日志文件头开始
+func hasCryptHeader(path string) bool {
+	f, erro := os.Open(path)
+	if erro != nil {
+		return false
+	}
+	defer f.Close()
+	magic := make([]byte, len(cryptMagic))
+	if _, erro = io.ReadFull(f, magic); erro != nil {
+		return false
+	}
+	return string(magic) == cryptMagic
+}
+
 // Write 实现io.Write接口
 // 主要添加了分割文件的功能
 func (o *WriteIO) Write(data []byte) (int, error) {
@@ -90,11 +149,19 @@ func (o *WriteIO) Write(data []byte) (int, error) {
 			return 0, erro
 		}
 	}
-	size, erro := o.out.Write(data)
+	frame := data
+	if o.cryptkey != nil {
+		var erro error
+		if frame, erro = encodeCryptFrame(data, o.cryptkey, o.mackey); erro != nil {
+			return 0, erro
+		}
+	}
+	size, erro := o.out.Write(frame)
 	if erro != nil {
 		return 0, erro
 	}
 	o.wsize += int64(size)
+	size = len(data)
 	if o.wsize > o.maxsize {
 		if erro = o.split(); erro != nil {
 			return size, erro